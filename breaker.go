@@ -0,0 +1,152 @@
+package simpleConnPool
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+====== 连接创建熔断器 circuit breaker =======
+*/
+
+//defaultCircuitCooldown 未设置 CircuitCooldown 时熔断持续的时间
+const defaultCircuitCooldown = 5 * time.Second
+
+//CircuitState 熔断器状态
+type CircuitState int32
+
+const (
+	CircuitClosed   CircuitState = iota //正常 允许创建连接
+	CircuitOpen                         //熔断中 创建连接直接返回 ErrCircuitOpen
+	CircuitHalfOpen                     //冷却结束 只允许一个试探连接
+)
+
+//String 返回熔断器状态名称
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+//MarshalText 实现 encoding.TextMarshaler 使 Stats 序列化为 JSON 时输出状态名称
+func (s CircuitState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+//circuitBreaker 连续创建连接失败达到阈值后熔断 冷却结束后放行一个试探连接
+type circuitBreaker struct {
+	threshold int32         //窗口内连续失败的熔断阈值
+	window    time.Duration //统计连续失败的时间窗口 小于等于0表示不限制
+	cooldown  time.Duration //熔断持续时间
+	logger    Logger        //日志
+	clock     clock         //时间源
+
+	mu           sync.Mutex   //保护以下字段
+	state        CircuitState //当前状态
+	failures     int32        //窗口内连续失败次数
+	firstFailure time.Time    //窗口内第一次失败的时间
+	openedAt     time.Time    //最近一次熔断的时间
+}
+
+//newCircuitBreaker 构造熔断器 threshold 小于等于0时返回 nil 表示不启用
+func newCircuitBreaker(threshold int32, window, cooldown time.Duration, logger Logger, clk clock) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	if cooldown <= 0 {
+		cooldown = defaultCircuitCooldown
+	}
+	return &circuitBreaker{
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		logger:    logger,
+		clock:     clk,
+	}
+}
+
+//allow 判断是否允许创建连接 熔断中返回 ErrCircuitOpen 冷却结束后只放行一个试探请求
+//状态变化在 mu 下记录 日志在释放 mu 之后输出 不会阻塞创建连接的其他请求
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	switch b.state {
+	case CircuitOpen:
+		if b.clock.Now().Sub(b.openedAt) < b.cooldown {
+			b.mu.Unlock()
+			return ErrCircuitOpen
+		}
+		b.state = CircuitHalfOpen
+		b.mu.Unlock()
+		b.logger.Debugf("simpleConnPool: circuit half-open, probing backend")
+		return nil
+	case CircuitHalfOpen:
+		//已有试探请求在进行中
+		b.mu.Unlock()
+		return ErrCircuitOpen
+	}
+	b.mu.Unlock()
+	return nil
+}
+
+//success 记录一次创建成功 重置失败计数并关闭熔断
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	recovered := b.state == CircuitHalfOpen
+	b.state = CircuitClosed
+	b.failures = 0
+	b.mu.Unlock()
+	if recovered {
+		b.logger.Warnf("simpleConnPool: circuit closed, backend recovered")
+	}
+}
+
+//failure 记录一次创建失败 试探失败或连续失败达到阈值时熔断
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	now := b.clock.Now()
+	if b.state == CircuitHalfOpen {
+		b.open(now)
+		b.mu.Unlock()
+		b.logger.Warnf("simpleConnPool: circuit probe failed, cooling down for %v", b.cooldown)
+		return
+	}
+	if b.window > 0 && b.failures > 0 && now.Sub(b.firstFailure) > b.window {
+		b.failures = 0
+	}
+	if b.failures == 0 {
+		b.firstFailure = now
+	}
+	b.failures++
+	failures := b.failures
+	opened := failures >= b.threshold
+	if opened {
+		b.open(now)
+	}
+	b.mu.Unlock()
+	if opened {
+		b.logger.Warnf("simpleConnPool: circuit opened after %d consecutive factory errors, cooling down for %v", failures, b.cooldown)
+	}
+}
+
+//open 进入熔断状态 调用方需持有 mu
+func (b *circuitBreaker) open(now time.Time) {
+	b.state = CircuitOpen
+	b.openedAt = now
+	b.failures = 0
+}
+
+//currentState 返回熔断器当前状态 未启用时为 CircuitClosed
+func (b *circuitBreaker) currentState() CircuitState {
+	if b == nil {
+		return CircuitClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -0,0 +1,47 @@
+package simpleConnPool
+
+import "time"
+
+/*
+====== 时间源 便于测试中替换 =======
+*/
+
+//clock 连接池使用的时间源 默认为 realClock 测试中可以通过 Config.clock 注入可控的实现
+type clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) timer
+	NewTicker(d time.Duration) ticker
+}
+
+//timer 与 time.Timer 相同的定时器
+type timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+//ticker 与 time.Ticker 相同的周期定时器
+type ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+//realClock 使用 time 包的时间源
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTimer(d time.Duration) timer { return realTimer{time.NewTimer(d)} }
+
+func (realClock) NewTicker(d time.Duration) ticker { return realTicker{time.NewTicker(d)} }
+
+type realTimer struct{ t *time.Timer }
+
+func (t realTimer) C() <-chan time.Time { return t.t.C }
+
+func (t realTimer) Stop() bool { return t.t.Stop() }
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+
+func (t realTicker) Stop() { t.t.Stop() }
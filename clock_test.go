@@ -0,0 +1,255 @@
+package simpleConnPool
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//fakeClock 测试用的时间源 只有调用 Advance 时时间才会前进
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeTimer
+}
+
+//fakeTimer 同时用作 timer 与 ticker period 大于0时为 ticker
+type fakeTimer struct {
+	c       chan time.Time
+	when    time.Time
+	period  time.Duration
+	stopped bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(0, 0)}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) add(d, period time.Duration) *fakeTimer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTimer{c: make(chan time.Time, 1), when: f.now.Add(d), period: period}
+	f.waiters = append(f.waiters, t)
+	return t
+}
+
+func (f *fakeClock) NewTimer(d time.Duration) timer { return fakeTimerHandle{f, f.add(d, 0)} }
+
+func (f *fakeClock) NewTicker(d time.Duration) ticker { return fakeTickerHandle{f, f.add(d, d)} }
+
+//Advance 将时间前进 d 触发所有到期的 timer 与 ticker
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	kept := f.waiters[:0]
+	for _, t := range f.waiters {
+		if t.stopped {
+			continue
+		}
+		for !t.when.After(f.now) {
+			//与 time.Ticker 相同 接收方过慢时丢弃
+			select {
+			case t.c <- t.when:
+			default:
+			}
+			if t.period <= 0 {
+				t.stopped = true
+				break
+			}
+			t.when = t.when.Add(t.period)
+		}
+		if !t.stopped {
+			kept = append(kept, t)
+		}
+	}
+	f.waiters = kept
+}
+
+//tickers 返回未停止的 ticker 数量
+func (f *fakeClock) tickers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, t := range f.waiters {
+		if t.period > 0 && !t.stopped {
+			n++
+		}
+	}
+	return n
+}
+
+//pending 返回已经触发但还没有被接收的 timer 与 ticker 数量
+func (f *fakeClock) pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, t := range f.waiters {
+		if len(t.c) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeClock) stop(t *fakeTimer) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	active := !t.stopped
+	t.stopped = true
+	return active
+}
+
+type fakeTimerHandle struct {
+	f *fakeClock
+	t *fakeTimer
+}
+
+func (h fakeTimerHandle) C() <-chan time.Time { return h.t.c }
+
+func (h fakeTimerHandle) Stop() bool { return h.f.stop(h.t) }
+
+type fakeTickerHandle struct {
+	f *fakeClock
+	t *fakeTimer
+}
+
+func (h fakeTickerHandle) C() <-chan time.Time { return h.t.c }
+
+func (h fakeTickerHandle) Stop() { h.f.stop(h.t) }
+
+//waitFor 不睡眠地等待 cond 成立 用于等待后台协程处理完 fakeClock 触发的事件
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for i := 0; !cond(); i++ {
+		if i > 1e6 {
+			t.Fatal("condition not reached")
+		}
+		runtime.Gosched()
+	}
+}
+
+func TestFakeClockIdleReaping(t *testing.T) {
+	clk := newFakeClock()
+	cfg := newTestConfig()
+	cfg.IdleTimeout = time.Minute
+	cfg.MaintainInterval = 10 * time.Second
+	cfg.clock = clk
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	waitFor(t, func() bool { return clk.tickers() == 1 })
+
+	a, _ := p.Get()
+	b, _ := p.Get()
+	_ = p.Put(a)
+	clk.Advance(30 * time.Second)
+	_ = p.Put(b)
+
+	//a 空闲 60s 后失效 b 仍在空闲时间内
+	clk.Advance(40 * time.Second)
+	waitFor(t, func() bool { return p.IdleLen() == 1 })
+	if conn, _ := p.TryGet(); conn != b {
+		t.Fatalf("TryGet returned %v, want the connection that has not expired", conn)
+	} else {
+		_ = p.Put(conn)
+	}
+
+	//b 也失效后空闲队列被清空
+	clk.Advance(2 * time.Minute)
+	waitFor(t, func() bool { return p.IdleLen() == 0 })
+	if s := p.Stats(); s.OpeningConn != 0 {
+		t.Fatalf("OpeningConn = %d after reaping, want 0", s.OpeningConn)
+	}
+}
+
+func TestPinInitial(t *testing.T) {
+	clk := newFakeClock()
+	cfg := newTestConfig()
+	cfg.InitialCap = 2
+	cfg.PinInitial = true
+	cfg.IdleTimeout = time.Minute
+	cfg.MaxLifetime = 2 * time.Minute
+	cfg.MaintainInterval = 10 * time.Second
+	cfg.clock = clk
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	waitFor(t, func() bool { return clk.tickers() == 1 })
+
+	//借出两个固定连接与一个新建连接后全部归还
+	conns := make([]any, 3)
+	for i := range conns {
+		conns[i], _ = p.Get()
+	}
+	for _, conn := range conns {
+		_ = p.Put(conn)
+	}
+
+	//空闲超时与最大存活时间都已经过去 只有非固定连接被回收
+	for i := 0; i < 30; i++ {
+		clk.Advance(10 * time.Second)
+	}
+	waitFor(t, func() bool { return p.Len() == 2 })
+	ids := map[int]bool{}
+	for i := 0; i < 2; i++ {
+		conn, _ := p.TryGet()
+		ids[conn.(*testConn).id] = true
+		defer p.Put(conn)
+	}
+	if !ids[1] || !ids[2] {
+		t.Fatalf("connections left after reaping: %v, want the pinned connections 1 and 2", ids)
+	}
+}
+
+func TestPinInitialReplacesDeadConn(t *testing.T) {
+	clk := newFakeClock()
+	cfg := newTestConfig()
+	cfg.InitialCap = 2
+	cfg.PinInitial = true
+	cfg.IdleTimeout = time.Minute
+	cfg.MaintainInterval = 10 * time.Second
+	cfg.clock = clk
+	var dead int32
+	cfg.HealthCheck = func(conn interface{}) error {
+		if conn.(*testConn).id == int(atomic.LoadInt32(&dead)) {
+			return errors.New("connection reset")
+		}
+		return nil
+	}
+	var closed int32
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	waitFor(t, func() bool { return clk.tickers() == 2 })
+
+	//固定连接 1 存活检测失败后被替换 替换的连接同样是固定连接 不会被空闲超时回收
+	atomic.StoreInt32(&dead, 1)
+	clk.Advance(10 * time.Second)
+	waitFor(t, func() bool {
+		s := p.Stats()
+		return atomic.LoadInt32(&closed) == 1 && s.IdleCount == 2 && s.OpeningConn == 2 && atomic.LoadInt32(&p.(*connectionPool).pinnedConn) == 2
+	})
+	for i := 0; i < 12; i++ {
+		clk.Advance(10 * time.Second)
+	}
+	if n := atomic.LoadInt32(&closed); n != 1 || p.IdleLen() != 2 {
+		t.Fatalf("closed %d connections with %d idle after the idle timeout, want 1 and 2", n, p.IdleLen())
+	}
+}
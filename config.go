@@ -0,0 +1,111 @@
+package simpleConnPool
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+//defaultIdleTimeout DefaultConfig 使用的连接最大空闲时间
+const defaultIdleTimeout = 5 * time.Minute
+
+//DefaultConfig 返回按 GOMAXPROCS 设置容量的默认配置 作为调整配置的起点
+//MaxCap 为 4*GOMAXPROCS MaxIdle 与 InitialCap 为 GOMAXPROCS 等待队列为 16*GOMAXPROCS 且不少于 100
+func DefaultConfig(factory func() (any, error), close func(any) error) *Config {
+	return defaultConfig(factory, close, runtime.GOMAXPROCS(0))
+}
+
+//defaultConfig 按 procs 个处理器生成默认配置
+func defaultConfig(factory func() (any, error), close func(any) error, procs int) *Config {
+	if procs < 1 {
+		procs = 1
+	}
+	n := int32(procs)
+	waitQueue := 16 * n
+	if waitQueue < defaultWaitQueue {
+		waitQueue = defaultWaitQueue
+	}
+	return &Config{
+		InitialCap:  n,
+		MaxCap:      4 * n,
+		MaxIdle:     n,
+		Factory:     factory,
+		Close:       close,
+		IdleTimeout: defaultIdleTimeout,
+		WaitTimeout: defaultWaitTimeout,
+		WaitQueue:   waitQueue,
+	}
+}
+
+//Check 检查配置是否合法 返回包含所有问题的组合错误 配置合法时返回 nil
+//每个问题都包装了对应的错误变量 可以通过 errors.Is 判断 例如 errors.Is(err, InvalidCapSet)
+//由于 Validate 已用作借出前的连接检测方法 配置检查方法命名为 Check
+func (cfg *Config) Check() error {
+	var errs []error
+	capErr := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]any{InvalidCapSet}, args...)...))
+	}
+	if cfg.InitialCap < 0 {
+		capErr("InitialCap(%d) 不能小于0", cfg.InitialCap)
+	}
+	if cfg.MaxIdle < 0 {
+		capErr("MaxIdle(%d) 不能小于0", cfg.MaxIdle)
+	}
+	if cfg.MinIdle < 0 {
+		capErr("MinIdle(%d) 不能小于0", cfg.MinIdle)
+	}
+	if cfg.WaitQueue < 0 {
+		capErr("WaitQueue(%d) 不能小于0", cfg.WaitQueue)
+	}
+	//MaxCap 小于等于0表示不限制连接数 此时不检查与 MaxCap 的关系
+	if cfg.MaxCap > 0 && cfg.MaxIdle > cfg.MaxCap {
+		capErr("MaxIdle(%d) 不能大于 MaxCap(%d)", cfg.MaxIdle, cfg.MaxCap)
+	}
+	//BurstCap 只有在限制了 MaxCap 时才有意义
+	if cfg.BurstCap > 0 && (cfg.MaxCap <= 0 || cfg.BurstCap <= cfg.MaxCap) {
+		capErr("BurstCap(%d) 必须大于 MaxCap(%d) 且 MaxCap 必须大于0", cfg.BurstCap, cfg.MaxCap)
+	}
+	if cfg.IdleOverflow && cfg.MaxIdle == 0 {
+		capErr("IdleOverflow 时 MaxIdle 必须大于0")
+	}
+	if cfg.NoIdle && cfg.MaxIdle > 0 {
+		capErr("NoIdle 时 MaxIdle(%d) 必须为0", cfg.MaxIdle)
+	}
+	//初始化的连接放入空闲队列 超出空闲队列上限的部分会在第一次归还或回收时被关闭 后台维护协程又会按 InitialCap 补足
+	//因此 InitialCap 不能大于空闲队列的上限 开启 IdleOverflow 时上限为 MaxCap 否则为 MaxIdle
+	//MaxIdle 不大于 MaxCap 已经在上面检查 未开启 IdleOverflow 时不再重复检查 InitialCap 与 MaxCap
+	if cfg.IdleOverflow {
+		if cfg.MaxCap > 0 && cfg.InitialCap > cfg.MaxCap {
+			capErr("InitialCap(%d) 不能大于 MaxCap(%d)", cfg.InitialCap, cfg.MaxCap)
+		}
+	} else if cfg.InitialCap > cfg.MaxIdle {
+		capErr("InitialCap(%d) 不能大于 MaxIdle(%d)", cfg.InitialCap, cfg.MaxIdle)
+	}
+	if cfg.MinIdle > cfg.MaxIdle {
+		capErr("MinIdle(%d) 不能大于 MaxIdle(%d)", cfg.MinIdle, cfg.MaxIdle)
+	}
+	if cfg.Factory == nil && cfg.FactoryContext == nil {
+		errs = append(errs, InvalidFactorySet)
+	}
+	if cfg.IdleTimeout < 0 {
+		errs = append(errs, fmt.Errorf("%w: IdleTimeout(%v) 不能小于0", InvalidTimeoutSet, cfg.IdleTimeout))
+	}
+	if cfg.IdleTimeoutJitter < 0 || cfg.IdleTimeoutJitter > 0 && cfg.IdleTimeoutJitter >= cfg.IdleTimeout {
+		errs = append(errs, fmt.Errorf("%w: IdleTimeoutJitter(%v) 必须大于等于0且小于 IdleTimeout(%v)", InvalidTimeoutSet, cfg.IdleTimeoutJitter, cfg.IdleTimeout))
+	}
+	return errors.Join(errs...)
+}
+
+//String 返回配置的单行描述 方法类配置只显示是否设置
+func (cfg *Config) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Config{InitialCap: %d, MaxCap: %d, MaxIdle: %d, MinIdle: %d, WaitQueue: %d",
+		cfg.InitialCap, cfg.MaxCap, cfg.MaxIdle, cfg.MinIdle, cfg.WaitQueue)
+	fmt.Fprintf(&b, ", IdleTimeout: %v, WaitTimeout: %v, MaxLifetime: %v, MaxUsage: %d, Strategy: %v",
+		cfg.IdleTimeout, cfg.WaitTimeout, cfg.MaxLifetime, cfg.MaxUsage, cfg.Strategy)
+	fmt.Fprintf(&b, ", Factory: %t, FactoryContext: %t, Close: %t, Validate: %t, HealthCheck: %t}",
+		cfg.Factory != nil, cfg.FactoryContext != nil, cfg.Close != nil, cfg.Validate != nil, cfg.HealthCheck != nil)
+	return b.String()
+}
@@ -0,0 +1,124 @@
+package simpleConnPool
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   []error  //组合错误中应包含的错误变量
+		msgs   []string //错误信息中应包含的内容
+	}{
+		{"valid", func(*Config) {}, nil, nil},
+		{"negative InitialCap", func(c *Config) { c.InitialCap = -1 }, []error{InvalidCapSet}, []string{"InitialCap(-1)"}},
+		{"unbounded MaxCap", func(c *Config) { c.MaxCap, c.MaxIdle = -1, 20 }, nil, nil},
+		{"negative MaxIdle", func(c *Config) { c.MaxIdle = -1 }, []error{InvalidCapSet}, []string{"MaxIdle(-1) 不能小于0"}},
+		{"negative MinIdle", func(c *Config) { c.MinIdle = -1 }, []error{InvalidCapSet}, []string{"MinIdle(-1)"}},
+		{"negative WaitQueue", func(c *Config) { c.WaitQueue = -1 }, []error{InvalidCapSet}, []string{"WaitQueue(-1)"}},
+		{"MaxIdle > MaxCap", func(c *Config) { c.MaxIdle = 11 }, []error{InvalidCapSet}, []string{"MaxIdle(11) 不能大于 MaxCap(10)"}},
+		{"InitialCap > MaxIdle", func(c *Config) { c.InitialCap = 6 }, []error{InvalidCapSet}, []string{"InitialCap(6) 不能大于 MaxIdle(5)"}},
+		{"InitialCap > MaxCap", func(c *Config) { c.InitialCap, c.MaxIdle, c.MaxCap = 5, 10, 3 }, []error{InvalidCapSet}, []string{"MaxIdle(10) 不能大于 MaxCap(3)"}},
+		{"IdleOverflow InitialCap > MaxCap", func(c *Config) { c.IdleOverflow, c.InitialCap, c.MaxIdle, c.MaxCap = true, 5, 2, 3 }, []error{InvalidCapSet}, []string{"InitialCap(5) 不能大于 MaxCap(3)"}},
+		{"IdleOverflow InitialCap > MaxIdle", func(c *Config) { c.IdleOverflow, c.InitialCap = true, 8 }, nil, nil},
+		{"InitialCap == MaxIdle == MaxCap", func(c *Config) { c.InitialCap, c.MaxIdle, c.MaxCap = 3, 3, 3 }, nil, nil},
+		{"zero MaxCap is unbounded", func(c *Config) { c.InitialCap, c.MaxIdle, c.MaxCap = 20, 20, 0 }, nil, nil},
+		{"zero MaxIdle", func(c *Config) { c.MaxIdle = 0 }, nil, nil},
+		{"MinIdle > MaxIdle", func(c *Config) { c.MinIdle = 6 }, []error{InvalidCapSet}, []string{"MinIdle(6) 不能大于 MaxIdle(5)"}},
+		{"BurstCap > MaxCap", func(c *Config) { c.BurstCap = 15 }, nil, nil},
+		{"BurstCap <= MaxCap", func(c *Config) { c.BurstCap = 10 }, []error{InvalidCapSet}, []string{"BurstCap(10) 必须大于 MaxCap(10)"}},
+		{"BurstCap with unbounded MaxCap", func(c *Config) { c.MaxCap, c.BurstCap = 0, 5 }, []error{InvalidCapSet}, []string{"BurstCap(5)"}},
+		{"IdleOverflow with zero MaxIdle", func(c *Config) { c.IdleOverflow, c.MaxIdle = true, 0 }, []error{InvalidCapSet}, []string{"IdleOverflow 时 MaxIdle 必须大于0"}},
+		{"NoIdle with MaxIdle", func(c *Config) { c.NoIdle = true }, []error{InvalidCapSet}, []string{"NoIdle 时 MaxIdle(5) 必须为0"}},
+		{"nil factory", func(c *Config) { c.Factory = nil }, []error{InvalidFactorySet}, nil},
+		{"nil close falls back to io.Closer", func(c *Config) { c.Close = nil }, nil, nil},
+		{"negative IdleTimeout", func(c *Config) { c.IdleTimeout = -time.Second }, []error{InvalidTimeoutSet}, []string{"IdleTimeout(-1s)"}},
+		{"IdleTimeoutJitter >= IdleTimeout", func(c *Config) { c.IdleTimeoutJitter = time.Minute }, []error{InvalidTimeoutSet}, []string{"IdleTimeoutJitter(1m0s)"}},
+		{"negative WaitTimeout waits forever", func(c *Config) { c.WaitTimeout = -time.Second }, nil, nil},
+		{
+			"combined",
+			func(c *Config) {
+				c.InitialCap = 6
+				c.MaxIdle = 11
+				c.Factory = nil
+				c.Close = nil
+				c.IdleTimeout = -time.Second
+			},
+			[]error{InvalidCapSet, InvalidFactorySet, InvalidTimeoutSet},
+			[]string{"MaxIdle(11) 不能大于 MaxCap(10)", "IdleTimeout(-1s)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			tt.modify(cfg)
+			err := cfg.Check()
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Check: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Check: got nil, want error")
+			}
+			for _, want := range tt.want {
+				if !errors.Is(err, want) {
+					t.Errorf("Check: %v does not wrap %v", err, want)
+				}
+			}
+			for _, msg := range tt.msgs {
+				if !strings.Contains(err.Error(), msg) {
+					t.Errorf("Check: %q does not mention %q", err, msg)
+				}
+			}
+			if _, newErr := NewPool(cfg); newErr == nil || newErr.Error() != err.Error() {
+				t.Errorf("NewPool: got %v, want %v", newErr, err)
+			}
+		})
+	}
+}
+
+func TestConfigString(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Strategy = LIFO
+	got := cfg.String()
+	for _, want := range []string{"MaxCap: 10", "MaxIdle: 5", "IdleTimeout: 1m0s", "WaitTimeout: 1s", "Strategy: LIFO", "Factory: true", "Validate: false"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("Config.String() = %q, missing %q", got, want)
+		}
+	}
+}
+
+func TestDefaultConfig(t *testing.T) {
+	factory := func() (any, error) { return &testConn{}, nil }
+	closeFn := func(any) error { return nil }
+	if cfg := DefaultConfig(factory, closeFn); cfg.MaxIdle != int32(runtime.GOMAXPROCS(0)) {
+		t.Fatalf("DefaultConfig MaxIdle = %d, want GOMAXPROCS %d", cfg.MaxIdle, runtime.GOMAXPROCS(0))
+	}
+	for _, tt := range []struct {
+		procs                                  int
+		initialCap, maxCap, maxIdle, waitQueue int32
+	}{
+		{1, 1, 4, 1, 100},
+		{4, 4, 16, 4, 100},
+		{64, 64, 256, 64, 1024},
+	} {
+		cfg := defaultConfig(factory, closeFn, tt.procs)
+		if err := cfg.Check(); err != nil {
+			t.Fatalf("procs %d: Check: %v", tt.procs, err)
+		}
+		if cfg.InitialCap != tt.initialCap || cfg.MaxCap != tt.maxCap || cfg.MaxIdle != tt.maxIdle || cfg.WaitQueue != tt.waitQueue {
+			t.Fatalf("procs %d: InitialCap/MaxCap/MaxIdle/WaitQueue = %d/%d/%d/%d, want %d/%d/%d/%d", tt.procs,
+				cfg.InitialCap, cfg.MaxCap, cfg.MaxIdle, cfg.WaitQueue, tt.initialCap, tt.maxCap, tt.maxIdle, tt.waitQueue)
+		}
+		if cfg.WaitTimeout <= 0 || cfg.IdleTimeout <= 0 {
+			t.Fatalf("procs %d: WaitTimeout/IdleTimeout = %v/%v, want both set", tt.procs, cfg.WaitTimeout, cfg.IdleTimeout)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package simpleConnPool
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+//WithMeta 可选接口 Factory 返回的连接实现该接口时 其元数据会出现在 PooledConn 句柄以及泄漏和存活检测日志中
+//例如协商的协议版本 服务端标识等 未实现该接口的连接不受影响
+type WithMeta interface {
+	PoolMeta() map[string]any
+}
+
+//connMeta 返回连接的元数据 连接未实现 WithMeta 时返回 nil
+func connMeta(conn any) map[string]any {
+	if m, ok := conn.(WithMeta); ok {
+		return m.PoolMeta()
+	}
+	return nil
+}
+
+//metaSuffix 返回追加到日志中的连接元数据描述 没有元数据时返回空字符串
+func metaSuffix(conn any) string {
+	meta := connMeta(conn)
+	if len(meta) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (meta: %v)", meta)
+}
+
+//PooledConn 连接池借出的连接句柄
+//使用完毕后调用 Release 归还 连接损坏时调用 Discard 关闭 两者只有第一次调用生效
+type PooledConn struct {
+	pool     Pool
+	conn     any
+	released int32 //是否已经归还或关闭 1 表示已处理
+}
+
+//newPooledConn 包装一个从 pool 借出的连接
+func newPooledConn(pool Pool, conn any) *PooledConn {
+	return &PooledConn{pool: pool, conn: conn}
+}
+
+//NewPooledConn 包装一个从 pool 借出的连接 供其他包中的 Pool 实现 GetConn 使用
+func NewPooledConn(pool Pool, conn any) *PooledConn {
+	return newPooledConn(pool, conn)
+}
+
+//Raw 返回原始连接
+func (pc *PooledConn) Raw() any {
+	return pc.conn
+}
+
+//Meta 返回连接的元数据 连接未实现 WithMeta 时返回 nil
+func (pc *PooledConn) Meta() map[string]any {
+	return connMeta(pc.conn)
+}
+
+//Release 将连接归还连接池 重复调用不会产生任何效果 将句柄传给 Put 与调用 Release 相同
+func (pc *PooledConn) Release() error {
+	if !atomic.CompareAndSwapInt32(&pc.released, 0, 1) {
+		return nil
+	}
+	return pc.pool.Put(pc.conn)
+}
+
+//Discard 关闭连接而不归还连接池 连接出现 I/O 错误时调用 重复调用不会产生任何效果
+func (pc *PooledConn) Discard() error {
+	if !atomic.CompareAndSwapInt32(&pc.released, 0, 1) {
+		return nil
+	}
+	return pc.pool.Invalidate(pc.conn)
+}
+
+//GetConn 向连接池中获取一个连接 返回连接句柄
+func (c *connectionPool) GetConn() (*PooledConn, error) {
+	conn, err := c.Get()
+	if err != nil {
+		return nil, err
+	}
+	return newPooledConn(c, conn), nil
+}
@@ -0,0 +1,61 @@
+package simpleConnPool
+
+import (
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+//connIndex 以连接为 key 的并发安全映射 用于跟踪借出的连接以及连接所属的分片或后端
+//设置了 keyFunc 时使用其返回值作为 key 否则可比较的连接直接作为 key
+//不可比较的连接 例如包含切片的结构体 以接口中保存的数据指针作为 key 内容相同的两个连接也能区分
+//因此需要归还 Get 返回的同一个值 类型断言后重新赋值给 any 得到的是新的副本 找不到对应的记录 这种情况应设置 keyFunc
+type connIndex struct {
+	keyFunc func(any) any //从连接得到可比较的 key 为空时使用默认规则
+
+	m sync.Map //key 为连接本身 keyFunc 的返回值或 identityKey
+}
+
+//identityKey 不可比较的连接的 key 与可比较的连接本身不会相等
+type identityKey struct {
+	p unsafe.Pointer //接口中保存的数据指针
+}
+
+//key 返回连接的 key
+func (ix *connIndex) key(conn any) any {
+	if ix.keyFunc != nil {
+		return ix.keyFunc(conn)
+	}
+	if t := reflect.TypeOf(conn); t != nil && (!t.Comparable() || !comparableValue(conn)) {
+		return identityKey{p: (*[2]unsafe.Pointer)(unsafe.Pointer(&conn))[1]}
+	}
+	return conn
+}
+
+//comparableValue 返回 conn 能否作为 map 的 key 找回对应的记录
+//类型可比较但接口字段保存了不可比较的值时比较会 panic 包含 NaN 时不等于自身 两种情况都返回 false
+func comparableValue(conn any) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return conn == conn
+}
+
+//Store 记录连接对应的值
+func (ix *connIndex) Store(conn, value any) {
+	ix.m.Store(ix.key(conn), value)
+}
+
+//LoadAndDelete 取出并删除连接对应的值 没有记录时返回 false
+func (ix *connIndex) LoadAndDelete(conn any) (any, bool) {
+	return ix.m.LoadAndDelete(ix.key(conn))
+}
+
+//Range 依次对每个记录的值调用 f f 返回 false 时停止
+func (ix *connIndex) Range(f func(value any) bool) {
+	ix.m.Range(func(_, v any) bool {
+		return f(v)
+	})
+}
@@ -0,0 +1,153 @@
+package simpleConnPool
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPooledConnRelease(t *testing.T) {
+	p, err := NewPool(newTestConfig())
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	pc, err := p.GetConn()
+	if err != nil {
+		t.Fatalf("GetConn: %v", err)
+	}
+	raw := pc.Raw()
+	if _, ok := raw.(*testConn); !ok {
+		t.Fatalf("Raw returned %T, want *testConn", raw)
+	}
+	if err := pc.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	//重复归还不会再次放入连接池
+	if err := pc.Release(); err != nil {
+		t.Fatalf("second Release: %v", err)
+	}
+	if p.IdleLen() != 1 || p.ActiveLen() != 0 {
+		t.Fatalf("IdleLen/ActiveLen = %d/%d, want 1/0", p.IdleLen(), p.ActiveLen())
+	}
+	conn, _ := p.Get()
+	if conn != raw {
+		t.Fatalf("Get returned %#v, want the released connection", conn)
+	}
+}
+
+func TestPooledConnDiscard(t *testing.T) {
+	cfg := newTestConfig()
+	var closed int32
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	pc, err := p.GetConn()
+	if err != nil {
+		t.Fatalf("GetConn: %v", err)
+	}
+	if err := pc.Discard(); err != nil {
+		t.Fatalf("Discard: %v", err)
+	}
+	if err := pc.Release(); err != nil {
+		t.Fatalf("Release after Discard: %v", err)
+	}
+	if got := atomic.LoadInt32(&closed); got != 1 {
+		t.Fatalf("closed %d connections, want 1", got)
+	}
+	if p.Len() != 0 {
+		t.Fatalf("Len = %d, want 0 after discard", p.Len())
+	}
+}
+
+func TestPutPooledConn(t *testing.T) {
+	p, err := NewPool(newTestConfig())
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	pc, err := p.GetConn()
+	if err != nil {
+		t.Fatalf("GetConn: %v", err)
+	}
+	if err := p.Put(pc); err != nil {
+		t.Fatalf("Put(handle): %v", err)
+	}
+	//句柄已经标记为归还 之后的 Release 与 Put 不会再次放入连接池
+	if err := pc.Release(); err != nil {
+		t.Fatalf("Release after Put: %v", err)
+	}
+	if err := p.Put(pc); err != nil {
+		t.Fatalf("second Put(handle): %v", err)
+	}
+	if p.IdleLen() != 1 || p.ActiveLen() != 0 {
+		t.Fatalf("IdleLen/ActiveLen = %d/%d, want 1/0", p.IdleLen(), p.ActiveLen())
+	}
+	a, _ := p.Get()
+	b, _ := p.Get()
+	if a != pc.Raw() || a == b {
+		t.Fatalf("Get returned %v and %v, want the handle connection exactly once", a, b)
+	}
+}
+
+//metaConn 实现 WithMeta 的测试连接
+type metaConn struct {
+	version string
+}
+
+func (c *metaConn) PoolMeta() map[string]any {
+	return map[string]any{"version": c.version}
+}
+
+func TestPooledConnMeta(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Factory = func() (interface{}, error) { return &metaConn{version: "v2"}, nil }
+	cfg.LeakThreshold = 10 * time.Millisecond
+	cfg.MaintainInterval = 5 * time.Millisecond
+	logger := &captureLogger{}
+	cfg.Logger = logger
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	pc, err := p.GetConn()
+	if err != nil {
+		t.Fatalf("GetConn: %v", err)
+	}
+	if got := pc.Meta()["version"]; got != "v2" {
+		t.Fatalf("Meta()[version] = %v, want v2", got)
+	}
+	//泄漏警告中包含连接的元数据
+	time.Sleep(50 * time.Millisecond)
+	logger.mu.Lock()
+	warns := strings.Join(logger.warns, "\n")
+	logger.mu.Unlock()
+	if !strings.Contains(warns, "version:v2") {
+		t.Fatalf("leak warning does not include connection meta: %q", warns)
+	}
+	_ = pc.Release()
+
+	//未实现 WithMeta 的连接没有元数据
+	plain, err := NewPool(newTestConfig())
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer plain.Shutdown()
+	pc, err = plain.GetConn()
+	if err != nil {
+		t.Fatalf("GetConn: %v", err)
+	}
+	if meta := pc.Meta(); meta != nil {
+		t.Fatalf("Meta() = %v, want nil", meta)
+	}
+	_ = pc.Release()
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package simpleConnPool
+
+import "net"
+
+//connCheck 检测连接是否已被对端关闭
+func connCheck(conn net.Conn) error {
+	return deadlineCheck(conn)
+}
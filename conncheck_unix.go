@@ -0,0 +1,44 @@
+//go:build unix
+
+package simpleConnPool
+
+import (
+	"errors"
+	"io"
+	"net"
+	"syscall"
+)
+
+//connCheck 检测连接是否已被对端关闭
+//支持 syscall.Conn 的连接使用非阻塞的 MSG_PEEK 读取 不会消耗数据 其余连接使用 deadlineCheck
+func connCheck(conn net.Conn) error {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return deadlineCheck(conn)
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var checkErr error
+	err = rc.Read(func(fd uintptr) bool {
+		var buf [1]byte
+		n, _, err := syscall.Recvfrom(int(fd), buf[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		switch {
+		case n == 0 && err == nil:
+			checkErr = io.EOF
+		case n > 0:
+			checkErr = errUnexpectedRead
+		case errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EWOULDBLOCK):
+			checkErr = nil
+		default:
+			checkErr = err
+		}
+		//无论结果如何都不等待连接可读
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return checkErr
+}
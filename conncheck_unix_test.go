@@ -0,0 +1,56 @@
+//go:build unix
+
+package simpleConnPool
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestConnPoolTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen: %v", err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+	var dialer net.Dialer
+	p, err := NewConnPool(func(ctx context.Context) (net.Conn, error) {
+		return dialer.DialContext(ctx, "tcp", ln.Addr().String())
+	})
+	if err != nil {
+		t.Fatalf("NewConnPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	conn, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	_ = p.Put(conn)
+	server := <-accepted
+	_ = server.Close()
+	//等待 FIN 到达
+	for i := 0; i < 100 && connCheck(conn) == nil; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	fresh, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get after peer close: %v", err)
+	}
+	defer p.Put(fresh)
+	if fresh == conn {
+		t.Fatal("TCP connection closed by peer was handed out")
+	}
+}
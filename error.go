@@ -1,13 +1,58 @@
 package simpleConnPool
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	PoolClosed              = errors.New("连接池已经关闭！")
+	GetConnectionTimeout    = errors.New("获取链接超时")
+	ErrWaitQueueFull        = errors.New("等待队列已满")
+	ConnectionIsNull        = errors.New("连接为空")
+	InvalidCapSet           = errors.New("无效容量设置")
+	InvalidFactorySet       = errors.New("无效factory函数设置")
+	InvalidCloseSet         = errors.New("无效close函数设置")
+	InvalidTimeoutSet       = errors.New("无效超时时间设置")
+	InitPoolErr             = errors.New("初始化连接池错误")
+	ErrPoolExhausted        = errors.New("连接池已耗尽")
+	ErrDrainTimeout         = errors.New("等待借出连接归还超时")
+	ErrUnknownConnection    = errors.New("连接不是由本连接池借出或已经归还")
+	ErrExpvarExists         = errors.New("expvar 变量名已经被注册")
+	ErrInvalidBackend       = errors.New("无效后端设置")
+	ErrCircuitOpen          = errors.New("创建连接失败次数过多 熔断中")
+	ErrPoolExists           = errors.New("连接池名称已经被注册")
+	ErrNoHealthyConnections = errors.New("连续多个连接检测失败 没有可用的连接")
+	ErrCreateTimeout        = errors.New("创建连接超时")
+	ErrBrokenConn           = errors.New("连接已损坏")
+	ErrForcedShutdown       = errors.New("等待借出连接归还超时 已强制关闭未归还的连接")
+)
 
+//以 Err 开头的别名 与原变量是同一个值 == 与 errors.Is 对两者的结果相同
 var (
-	PoolClosed           = errors.New("连接池已经关闭！")
-	GetConnectionTimeout = errors.New("获取链接超时")
-	ConnectionIsNull     = errors.New("连接为空")
-	InvalidCapSet        = errors.New("无效容量设置")
-	InvalidFactorySet    = errors.New("无效factory函数设置")
-	InvalidCloseSet      = errors.New("无效close函数设置")
-	InitPoolErr          = errors.New("初始化连接池错误")
+	ErrPoolClosed           = PoolClosed
+	ErrGetConnectionTimeout = GetConnectionTimeout
+	ErrConnectionIsNull     = ConnectionIsNull
+	ErrInvalidCapSet        = InvalidCapSet
+	ErrInvalidFactorySet    = InvalidFactorySet
+	ErrInvalidCloseSet      = InvalidCloseSet
+	ErrInvalidTimeoutSet    = InvalidTimeoutSet
+	ErrInitPool             = InitPoolErr
 )
+
+//WaitTimeoutError 等待连接超时时返回的错误 errors.Is(err, GetConnectionTimeout) 为 true
+//可以通过 errors.As 取出本次等待的时间
+type WaitTimeoutError struct {
+	Timeout time.Duration //本次获取使用的最大等待时间
+	Waited  time.Duration //实际的等待时间 包括等待等待队列空位的时间
+}
+
+func (e *WaitTimeoutError) Error() string {
+	return fmt.Sprintf("%v: 等待 %v 超时 实际等待 %v", GetConnectionTimeout, e.Timeout, e.Waited)
+}
+
+//Unwrap 返回 GetConnectionTimeout
+func (e *WaitTimeoutError) Unwrap() error {
+	return GetConnectionTimeout
+}
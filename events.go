@@ -0,0 +1,104 @@
+package simpleConnPool
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+/*
+====== 连接池事件流 =======
+*/
+
+//EventType 连接池事件类型
+type EventType int32
+
+const (
+	EventCreate  EventType = iota //创建了一个连接
+	EventBorrow                   //借出了一个连接
+	EventReturn                   //归还了一个连接
+	EventClose                    //关闭了一个连接
+	EventTimeout                  //等待连接超时
+	EventExpire                   //连接超过最大空闲时间或最大存活时间而失效 随后会有一个 EventClose
+)
+
+//defaultEventBuffer 未设置 EventBuffer 时事件 channel 的缓冲大小
+const defaultEventBuffer = 128
+
+//String 返回事件类型名称
+func (t EventType) String() string {
+	switch t {
+	case EventCreate:
+		return "Create"
+	case EventBorrow:
+		return "Borrow"
+	case EventReturn:
+		return "Return"
+	case EventClose:
+		return "Close"
+	case EventTimeout:
+		return "Timeout"
+	case EventExpire:
+		return "Expire"
+	}
+	return "Unknown"
+}
+
+//Event 连接池事件
+type Event struct {
+	Type EventType //事件类型
+	Time time.Time //事件发生的时间
+	Err  error     //EventClose 时为关闭方法返回的错误 其他事件为 nil
+}
+
+//Events 返回连接池的事件 channel 第一次调用时开始发布事件 多次调用返回同一个 channel
+//消费过慢导致缓冲已满时丢弃事件并计入 Stats 的 DroppedEvents 不会阻塞 Get 与 Put
+//Shutdown 之后 channel 被关闭
+func (c *connectionPool) Events() <-chan Event {
+	c.eventsMu.Lock()
+	defer c.eventsMu.Unlock()
+	if c.events == nil {
+		c.events = make(chan Event, c.eventBuffer)
+		if c.eventsClosed {
+			close(c.events)
+			return c.events
+		}
+		atomic.StoreInt32(&c.eventsOn, 1)
+	}
+	return c.events
+}
+
+//emit 发布一个事件 未调用过 Events 时直接返回
+func (c *connectionPool) emit(t EventType) {
+	c.emitErr(t, nil)
+}
+
+//emitErr 发布一个带错误的事件
+func (c *connectionPool) emitErr(t EventType, err error) {
+	if atomic.LoadInt32(&c.eventsOn) == 0 {
+		return
+	}
+	c.eventsMu.RLock()
+	defer c.eventsMu.RUnlock()
+	if c.eventsClosed {
+		return
+	}
+	select {
+	case c.events <- Event{Type: t, Time: c.clock.Now(), Err: err}:
+	default:
+		atomic.AddInt64(&c.counters.droppedEvents, 1)
+	}
+}
+
+//closeEvents 停止发布事件并关闭事件 channel
+func (c *connectionPool) closeEvents() {
+	c.eventsMu.Lock()
+	defer c.eventsMu.Unlock()
+	if c.eventsClosed {
+		return
+	}
+	c.eventsClosed = true
+	atomic.StoreInt32(&c.eventsOn, 0)
+	if c.events != nil {
+		close(c.events)
+	}
+}
@@ -0,0 +1,71 @@
+package simpleConnPool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	p, err := NewPool(newTestConfig())
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	events := p.Events()
+	if again := p.Events(); again != events {
+		t.Fatal("Events returned a different channel on the second call")
+	}
+
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	_ = p.Put(conn)
+	_ = p.Shutdown()
+
+	var got []EventType
+	for e := range events {
+		if e.Time.IsZero() {
+			t.Fatalf("event %v has no timestamp", e.Type)
+		}
+		got = append(got, e.Type)
+	}
+	want := []EventType{EventCreate, EventBorrow, EventReturn, EventClose}
+	if len(got) != len(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("events = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestEventsDropWhenFull(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.EventBuffer = 1
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	_ = p.Events()
+
+	//没有消费者时 Get 与 Put 不会阻塞 超出缓冲的事件被丢弃
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			conn, _ := p.Get()
+			_ = p.Put(conn)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Get/Put blocked on a full event channel")
+	}
+	//1 次创建 10 次借出 10 次归还 只有第一个事件进入缓冲
+	if dropped := p.Stats().DroppedEvents; dropped != 20 {
+		t.Fatalf("DroppedEvents = %d, want 20", dropped)
+	}
+}
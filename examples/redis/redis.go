@@ -0,0 +1,107 @@
+//Package redis 基于 simpleConnPool 的 Redis 连接池示例
+//只负责建立 TCP 连接 借出前通过 PING 检测连接 淘汰时发送 QUIT 后关闭 不解析其他命令
+package redis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"simpleConnPool"
+)
+
+var (
+	cmdPing   = []byte("*1\r\n$4\r\nPING\r\n")
+	cmdQuit   = []byte("*1\r\n$4\r\nQUIT\r\n")
+	replyPong = []byte("+PONG\r\n")
+)
+
+//errBadPong PING 的响应不是 +PONG
+var errBadPong = errors.New("redis: PING 响应不是 PONG")
+
+//ioTimeout PING 与 QUIT 的最长读写时间
+const ioTimeout = time.Second
+
+//RedisPool Redis 连接池 借出的是原始的 net.Conn 调用方自行读写 RESP 协议
+type RedisPool struct {
+	pool simpleConnPool.Pool
+}
+
+//New 构造一个连接 addr 的 Redis 连接池
+//cfg 中的 Factory FactoryContext Validate 与 Close 会被覆盖 其余配置项按原样使用
+func New(addr string, cfg simpleConnPool.Config) (*RedisPool, error) {
+	var dialer net.Dialer
+	cfg.Factory = nil
+	cfg.FactoryContext = func(ctx context.Context) (any, error) {
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+	cfg.Validate = func(conn any) error {
+		return ping(conn.(net.Conn))
+	}
+	cfg.Close = func(conn any) error {
+		return quit(conn.(net.Conn))
+	}
+	p, err := simpleConnPool.NewPool(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisPool{pool: p}, nil
+}
+
+//Get 向连接池中获取一个通过 PING 检测的连接
+func (p *RedisPool) Get(ctx context.Context) (net.Conn, error) {
+	conn, err := p.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn.(net.Conn), nil
+}
+
+//Put 向连接池中放入一个连接 连接上不能有未读取的响应
+func (p *RedisPool) Put(conn net.Conn) error {
+	return p.pool.Put(conn)
+}
+
+//Invalidate 关闭一个已损坏的借出连接 读写出错时应调用此方法
+func (p *RedisPool) Invalidate(conn net.Conn) error {
+	return p.pool.Invalidate(conn)
+}
+
+//Stats 返回连接池当前的运行状态
+func (p *RedisPool) Stats() simpleConnPool.Stats {
+	return p.pool.Stats()
+}
+
+//Shutdown 关闭连接池 空闲连接发送 QUIT 后关闭
+func (p *RedisPool) Shutdown() error {
+	return p.pool.Shutdown()
+}
+
+//ping 发送 PING 并读取 +PONG 响应
+func ping(conn net.Conn) error {
+	if err := conn.SetDeadline(time.Now().Add(ioTimeout)); err != nil {
+		return err
+	}
+	defer conn.SetDeadline(time.Time{})
+	if _, err := conn.Write(cmdPing); err != nil {
+		return err
+	}
+	reply := make([]byte, len(replyPong))
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if string(reply) != string(replyPong) {
+		return errBadPong
+	}
+	return nil
+}
+
+//quit 尽力发送 QUIT 后关闭连接 只返回关闭连接的错误
+func quit(conn net.Conn) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(ioTimeout)); err == nil {
+		_, _ = conn.Write(cmdQuit)
+	}
+	return conn.Close()
+}
@@ -0,0 +1,159 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"simpleConnPool"
+)
+
+//fakeRedis 只支持 PING 与 QUIT 的 Redis 服务端
+type fakeRedis struct {
+	ln net.Listener
+
+	mu    sync.Mutex
+	conns []net.Conn
+	quits int
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	s := &fakeRedis{ln: ln}
+	go s.serve()
+	t.Cleanup(func() { _ = ln.Close() })
+	return s
+}
+
+func (s *fakeRedis) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+//handle 按行读取 RESP 数组 只识别命令名
+func (s *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch strings.TrimSpace(line) {
+		case "PING":
+			_, _ = conn.Write([]byte("+PONG\r\n"))
+		case "QUIT":
+			s.mu.Lock()
+			s.quits++
+			s.mu.Unlock()
+			_, _ = conn.Write([]byte("+OK\r\n"))
+			return
+		}
+	}
+}
+
+//closeServerSide 从服务端关闭第 i 个连接
+func (s *fakeRedis) closeServerSide(i int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.conns[i].Close()
+}
+
+func (s *fakeRedis) counts() (conns, quits int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns), s.quits
+}
+
+//waitCounts 等待服务端的连接数与 QUIT 次数达到预期 最多等待 1s
+func (s *fakeRedis) waitCounts(wantConns, wantQuits int) (conns, quits int) {
+	deadline := time.Now().Add(time.Second)
+	for conns, quits = s.counts(); (conns != wantConns || quits != wantQuits) && time.Now().Before(deadline); conns, quits = s.counts() {
+		time.Sleep(time.Millisecond)
+	}
+	return conns, quits
+}
+
+func newTestPool(t *testing.T, s *fakeRedis) *RedisPool {
+	p, err := New(s.ln.Addr().String(), simpleConnPool.Config{
+		MaxCap:      2,
+		MaxIdle:     2,
+		WaitTimeout: time.Second,
+		WaitQueue:   2,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return p
+}
+
+func TestRedisPoolReuse(t *testing.T) {
+	s := newFakeRedis(t)
+	p := newTestPool(t, s)
+	ctx := context.Background()
+
+	conn, err := p.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	_ = p.Put(conn)
+	again, err := p.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if again != conn {
+		t.Fatal("healthy idle connection was not reused")
+	}
+	_ = p.Put(again)
+
+	//关闭连接池时空闲连接先发送 QUIT
+	_ = p.Shutdown()
+	if conns, quits := s.waitCounts(1, 1); conns != 1 || quits != 1 {
+		t.Fatalf("conns/quits = %d/%d, want 1/1", conns, quits)
+	}
+}
+
+func TestRedisPoolPingRejectsClosedConn(t *testing.T) {
+	s := newFakeRedis(t)
+	p := newTestPool(t, s)
+	defer p.Shutdown()
+	ctx := context.Background()
+
+	conn, err := p.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	_ = p.Put(conn)
+
+	//服务端关闭空闲连接后 PING 检测失败 连接池重新建立连接
+	s.closeServerSide(0)
+	fresh, err := p.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get after server close: %v", err)
+	}
+	if fresh == conn {
+		t.Fatal("closed connection passed PING validation")
+	}
+	if conns, _ := s.waitCounts(2, 0); conns != 2 {
+		t.Fatalf("server saw %d connections, want 2", conns)
+	}
+	if err := ping(fresh); err != nil {
+		t.Fatalf("ping on fresh connection: %v", err)
+	}
+	_ = p.Put(fresh)
+}
@@ -0,0 +1,28 @@
+package simpleConnPool
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+)
+
+/*
+====== 基于 expvar 的零依赖指标 =======
+*/
+
+//expvarMu 保证检查变量名与注册之间不会被其他注册打断
+var expvarMu sync.Mutex
+
+//PublishExpvar 将连接池的 Stats() 以 JSON 形式注册为名为 name 的 expvar 变量 可以通过 /debug/vars 查看
+//name 已经被注册时返回 ErrExpvarExists 而不是像 expvar.Publish 一样 panic
+func PublishExpvar(name string, p Pool) error {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("%w: %s", ErrExpvarExists, name)
+	}
+	expvar.Publish(name, expvar.Func(func() any {
+		return p.Stats()
+	}))
+	return nil
+}
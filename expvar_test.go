@@ -0,0 +1,68 @@
+package simpleConnPool
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPublishExpvar(t *testing.T) {
+	p, err := NewPool(newTestConfig())
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	other, err := NewPool(newTestConfig())
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer other.Shutdown()
+
+	//expvar 无法注销变量 使用唯一的变量名以支持 -count 多次运行
+	nameA, nameB := uniqueExpvarName("test_pool_a"), uniqueExpvarName("test_pool_b")
+	if err := PublishExpvar(nameA, p); err != nil {
+		t.Fatalf("PublishExpvar: %v", err)
+	}
+	if err := PublishExpvar(nameB, other); err != nil {
+		t.Fatalf("PublishExpvar second pool: %v", err)
+	}
+	if err := PublishExpvar(nameA, other); !errors.Is(err, ErrExpvarExists) {
+		t.Fatalf("PublishExpvar duplicate name: got %v, want ErrExpvarExists", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		conn, err := p.Get()
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		_ = p.Put(conn)
+	}
+	held, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer p.Put(held)
+
+	var stats struct {
+		ActiveCount int32
+		TotalGets   int64
+		Circuit     string
+	}
+	if err := json.Unmarshal([]byte(expvar.Get(nameA).String()), &stats); err != nil {
+		t.Fatalf("unmarshal expvar: %v", err)
+	}
+	if stats.TotalGets != 4 || stats.ActiveCount != 1 || stats.Circuit != "closed" {
+		t.Fatalf("expvar stats = %+v, want TotalGets 4 ActiveCount 1 Circuit closed", stats)
+	}
+}
+
+//expvarRuns 已经生成的 expvar 变量名数量
+var expvarRuns int32
+
+//uniqueExpvarName 返回带序号的 expvar 变量名
+func uniqueExpvarName(name string) string {
+	return fmt.Sprintf("%s_%d", name, atomic.AddInt32(&expvarRuns, 1))
+}
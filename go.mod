@@ -1,3 +1,3 @@
 module simpleConnPool
 
-go 1.18
+go 1.20
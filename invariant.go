@@ -0,0 +1,6 @@
+//go:build !pooldebug
+
+package simpleConnPool
+
+//checkOpening 检查正在运行的连接数 只在 pooldebug 构建标签下生效
+func checkOpening(int32) {}
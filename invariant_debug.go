@@ -0,0 +1,12 @@
+//go:build pooldebug
+
+package simpleConnPool
+
+import "fmt"
+
+//checkOpening 正在运行的连接数小于0时 panic 说明有连接被重复释放
+func checkOpening(n int32) {
+	if n < 0 {
+		panic(fmt.Sprintf("simpleConnPool: openingConn went negative (%d), a connection was released twice", n))
+	}
+}
@@ -0,0 +1,19 @@
+//go:build pooldebug
+
+package simpleConnPool
+
+import "testing"
+
+func TestDecOpeningPanicsWhenNegative(t *testing.T) {
+	p, err := NewPool(newTestConfig())
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	defer func() {
+		if recover() == nil {
+			t.Fatal("decOpening below zero did not panic")
+		}
+	}()
+	p.(*connectionPool).decOpening()
+}
@@ -0,0 +1,15 @@
+package simpleConnPool
+
+//Logger 连接池日志接口 用于输出连接池内部事件
+type Logger interface {
+	Debugf(format string, args ...any)
+	Warnf(format string, args ...any)
+	Errorf(format string, args ...any)
+}
+
+//nopLogger 默认日志 不输出任何内容
+type nopLogger struct{}
+
+func (nopLogger) Debugf(string, ...any) {}
+func (nopLogger) Warnf(string, ...any)  {}
+func (nopLogger) Errorf(string, ...any) {}
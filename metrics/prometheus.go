@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"simpleConnPool"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/*
+====== 连接池 Prometheus 指标采集 =======
+*/
+
+const namespace = "simple_conn_pool"
+
+//collector 连接池 Prometheus 指标采集器 每次采集时读取 Pool.Stats()
+type collector struct {
+	pool simpleConnPool.Pool
+
+	idle          *prometheus.Desc
+	active        *prometheus.Desc
+	opening       *prometheus.Desc
+	waiting       *prometheus.Desc
+	gets          *prometheus.Desc
+	timeouts      *prometheus.Desc
+	factoryErrors *prometheus.Desc
+}
+
+//NewPrometheusCollector 返回一个连接池指标采集器 所有指标带有 pool=name 标签
+//通过 prometheus.MustRegister 注册即可使用
+func NewPrometheusCollector(p simpleConnPool.Pool, name string) prometheus.Collector {
+	labels := prometheus.Labels{"pool": name}
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", metric), help, nil, labels)
+	}
+	return &collector{
+		pool:          p,
+		idle:          desc("idle_connections", "当前空闲连接数"),
+		active:        desc("active_connections", "当前已借出未归还的连接数"),
+		opening:       desc("open_connections", "当前正在运行的连接数"),
+		waiting:       desc("waiting_requests", "当前等待获取连接的请求数"),
+		gets:          desc("gets_total", "累计成功获取连接次数"),
+		timeouts:      desc("wait_timeouts_total", "累计等待连接超时次数"),
+		factoryErrors: desc("factory_errors_total", "累计创建连接失败次数"),
+	}
+}
+
+//Describe 实现 prometheus.Collector
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.idle
+	ch <- c.active
+	ch <- c.opening
+	ch <- c.waiting
+	ch <- c.gets
+	ch <- c.timeouts
+	ch <- c.factoryErrors
+}
+
+//Collect 实现 prometheus.Collector
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.pool.Stats()
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.IdleCount))
+	ch <- prometheus.MustNewConstMetric(c.active, prometheus.GaugeValue, float64(stats.ActiveCount))
+	ch <- prometheus.MustNewConstMetric(c.opening, prometheus.GaugeValue, float64(stats.OpeningConn))
+	ch <- prometheus.MustNewConstMetric(c.waiting, prometheus.GaugeValue, float64(stats.WaitingRequests))
+	ch <- prometheus.MustNewConstMetric(c.gets, prometheus.CounterValue, float64(stats.TotalGets))
+	ch <- prometheus.MustNewConstMetric(c.timeouts, prometheus.CounterValue, float64(stats.TotalTimeouts))
+	ch <- prometheus.MustNewConstMetric(c.factoryErrors, prometheus.CounterValue, float64(stats.TotalFactoryErrors))
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"simpleConnPool"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPrometheusCollector(t *testing.T) {
+	p, err := simpleConnPool.NewPool(&simpleConnPool.Config{
+		InitialCap:  1,
+		MaxCap:      4,
+		MaxIdle:     4,
+		Factory:     func() (interface{}, error) { return new(int), nil },
+		Close:       func(interface{}) error { return nil },
+		WaitTimeout: time.Second,
+		WaitQueue:   4,
+	})
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	a, _ := p.Get()
+	b, _ := p.Get()
+	_ = p.Put(a)
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewPrometheusCollector(p, "db"))
+
+	expected := `
+# HELP simple_conn_pool_active_connections 当前已借出未归还的连接数
+# TYPE simple_conn_pool_active_connections gauge
+simple_conn_pool_active_connections{pool="db"} 1
+# HELP simple_conn_pool_gets_total 累计成功获取连接次数
+# TYPE simple_conn_pool_gets_total counter
+simple_conn_pool_gets_total{pool="db"} 2
+# HELP simple_conn_pool_idle_connections 当前空闲连接数
+# TYPE simple_conn_pool_idle_connections gauge
+simple_conn_pool_idle_connections{pool="db"} 1
+# HELP simple_conn_pool_open_connections 当前正在运行的连接数
+# TYPE simple_conn_pool_open_connections gauge
+simple_conn_pool_open_connections{pool="db"} 2
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"simple_conn_pool_active_connections",
+		"simple_conn_pool_gets_total",
+		"simple_conn_pool_idle_connections",
+		"simple_conn_pool_open_connections",
+	); err != nil {
+		t.Fatal(err)
+	}
+	if n := testutil.CollectAndCount(NewPrometheusCollector(p, "db")); n != 7 {
+		t.Fatalf("collected %d metrics, want 7", n)
+	}
+	_ = p.Put(b)
+}
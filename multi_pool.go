@@ -0,0 +1,155 @@
+package simpleConnPool
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+====== 多后端加权连接池 =======
+*/
+
+//Backend 多后端连接池中的一个后端
+type Backend struct {
+	Name    string              //后端名称 用于 Stats 中区分后端
+	Factory func() (any, error) //生成该后端连接的方法
+	Close   func(any) error     //关闭该后端连接的方法
+	Weight  int                 //权重 新建连接按权重在后端之间分配
+}
+
+//BackendStats 单个后端的运行状态
+type BackendStats struct {
+	Name         string //后端名称
+	Weight       int    //权重
+	OpeningConn  int32  //当前属于该后端的连接数
+	TotalCreated int64  //累计在该后端创建的连接数
+}
+
+//backend 后端及其状态
+type backend struct {
+	Backend
+	current int //平滑加权轮询的当前权重 由 multiPool.mu 保护
+
+	openingConn  int32
+	totalCreated int64
+}
+
+//multiPool 多后端连接池 新建连接时按平滑加权轮询选择后端 每个连接记录其所属后端
+type multiPool struct {
+	Pool
+
+	backends []*backend
+	total    int //权重之和
+
+	mu     sync.Mutex //保护 current
+	owners connIndex  //连接所属的后端 key 由 KeyFunc 决定 value 为 *backend
+}
+
+//NewMultiPool 构造一个多后端连接池 新建连接按 Weight 在后端之间加权轮询分配
+//连接池的其他配置通过 opts 设置 Stats 的 Backends 字段包含每个后端的连接数
+func NewMultiPool(backends []Backend, opts ...Option) (Pool, error) {
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("%w: 至少需要一个后端", ErrInvalidBackend)
+	}
+	m := &multiPool{}
+	for i, b := range backends {
+		if b.Factory == nil || b.Close == nil || b.Weight <= 0 {
+			return nil, fmt.Errorf("%w: 第 %d 个后端 %q 需要 Factory Close 与大于0的 Weight", ErrInvalidBackend, i, b.Name)
+		}
+		m.backends = append(m.backends, &backend{Backend: b})
+		m.total += b.Weight
+	}
+	cfg := newConfig(m.factory, m.close, opts...)
+	m.owners.keyFunc = cfg.KeyFunc
+	p, err := NewPool(cfg)
+	if err != nil {
+		return nil, err
+	}
+	m.Pool = p
+	return m, nil
+}
+
+//pick 按平滑加权轮询选择一个后端
+func (m *multiPool) pick() *backend {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var best *backend
+	for _, b := range m.backends {
+		b.current += b.Weight
+		if best == nil || b.current > best.current {
+			best = b
+		}
+	}
+	best.current -= m.total
+	return best
+}
+
+//factory 在选中的后端上创建连接 并记录连接所属的后端
+func (m *multiPool) factory() (any, error) {
+	b := m.pick()
+	conn, err := b.Factory()
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt32(&b.openingConn, 1)
+	atomic.AddInt64(&b.totalCreated, 1)
+	m.owners.Store(conn, b)
+	return conn, nil
+}
+
+//close 使用连接所属后端的关闭方法关闭连接
+func (m *multiPool) close(conn any) error {
+	v, ok := m.owners.LoadAndDelete(conn)
+	if !ok {
+		return ErrUnknownConnection
+	}
+	b := v.(*backend)
+	atomic.AddInt32(&b.openingConn, -1)
+	return b.Close(conn)
+}
+
+//Reconfigure 修改连接池配置 cfg 中的 Factory FactoryContext Close 会被忽略 新建连接仍在各后端之间分配
+func (m *multiPool) Reconfigure(cfg *Config) error {
+	if cfg == nil {
+		return InvalidCapSet
+	}
+	backendCfg := *cfg
+	backendCfg.Factory = m.factory
+	backendCfg.FactoryContext = nil
+	backendCfg.Close = m.close
+	return m.Pool.Reconfigure(&backendCfg)
+}
+
+//Stats 返回连接池当前的运行状态 Backends 为每个后端的状态
+func (m *multiPool) Stats() Stats {
+	stats := m.Pool.Stats()
+	stats.Backends = m.backendStats(false)
+	return stats
+}
+
+//StatsSnapshotAndReset 返回连接池当前的运行状态 并将包括每个后端在内的累计计数器清零
+func (m *multiPool) StatsSnapshotAndReset() Stats {
+	stats := m.Pool.StatsSnapshotAndReset()
+	stats.Backends = m.backendStats(true)
+	return stats
+}
+
+//backendStats 返回每个后端的状态 reset 为 true 时将累计计数器清零
+func (m *multiPool) backendStats(reset bool) []BackendStats {
+	stats := make([]BackendStats, 0, len(m.backends))
+	for _, b := range m.backends {
+		s := BackendStats{
+			Name:        b.Name,
+			Weight:      b.Weight,
+			OpeningConn: atomic.LoadInt32(&b.openingConn),
+		}
+		if reset {
+			s.TotalCreated = atomic.SwapInt64(&b.totalCreated, 0)
+		} else {
+			s.TotalCreated = atomic.LoadInt64(&b.totalCreated)
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
@@ -0,0 +1,64 @@
+package simpleConnPool
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMultiPoolWeightedCreation(t *testing.T) {
+	newBackend := func(name string, weight int) Backend {
+		return Backend{
+			Name:    name,
+			Factory: func() (any, error) { return &testConn{}, nil },
+			Close:   func(any) error { return nil },
+			Weight:  weight,
+		}
+	}
+	p, err := NewMultiPool([]Backend{newBackend("primary", 3), newBackend("replica", 1)}, WithMaxCap(400), WithMaxIdle(0))
+	if err != nil {
+		t.Fatalf("NewMultiPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	//同时借出 400 个连接 每个连接都是新创建的
+	conns := make([]any, 0, 400)
+	for i := 0; i < 400; i++ {
+		conn, err := p.Get()
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		conns = append(conns, conn)
+	}
+	backends := p.Stats().Backends
+	if len(backends) != 2 {
+		t.Fatalf("Stats().Backends has %d entries, want 2", len(backends))
+	}
+	if backends[0].TotalCreated != 300 || backends[1].TotalCreated != 100 {
+		t.Fatalf("created %d/%d, want 300/100", backends[0].TotalCreated, backends[1].TotalCreated)
+	}
+
+	//MaxIdle 为0 归还的连接被其所属后端关闭
+	for _, conn := range conns[:200] {
+		_ = p.Put(conn)
+	}
+	backends = p.Stats().Backends
+	if got := backends[0].OpeningConn + backends[1].OpeningConn; got != 200 {
+		t.Fatalf("backend OpeningConn sum = %d, want 200", got)
+	}
+	if backends[0].OpeningConn != 150 || backends[1].OpeningConn != 50 {
+		t.Fatalf("backend OpeningConn = %d/%d, want 150/50", backends[0].OpeningConn, backends[1].OpeningConn)
+	}
+	for _, conn := range conns[200:] {
+		_ = p.Put(conn)
+	}
+}
+
+func TestNewMultiPoolInvalidBackend(t *testing.T) {
+	if _, err := NewMultiPool(nil); !errors.Is(err, ErrInvalidBackend) {
+		t.Fatalf("no backends: got %v, want ErrInvalidBackend", err)
+	}
+	_, err := NewMultiPool([]Backend{{Name: "a", Factory: func() (any, error) { return nil, nil }, Close: func(any) error { return nil }}})
+	if !errors.Is(err, ErrInvalidBackend) {
+		t.Fatalf("zero weight: got %v, want ErrInvalidBackend", err)
+	}
+}
@@ -0,0 +1,110 @@
+package simpleConnPool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+/*
+====== net.Conn 专用连接池 =======
+*/
+
+//errUnexpectedRead 空闲连接上收到了未预期的数据 连接状态已不可信
+var errUnexpectedRead = errors.New("空闲连接收到了未预期的数据")
+
+//NetPool net.Conn 专用连接池 连接的关闭方法自动设置为 net.Conn.Close
+type NetPool interface {
+	Get(ctx context.Context) (net.Conn, error)
+	Put(conn net.Conn) error
+	Invalidate(conn net.Conn) error
+	Stats() Stats
+	Shutdown() error
+}
+
+//netPool NetPool 的实现 对 Pool 进行包装
+type netPool struct {
+	pool Pool
+}
+
+//NewConnPool 构造一个 net.Conn 连接池 dial 用于建立连接 ctx 为 Get 传入的上下文
+//借出空闲连接前会检测连接是否已被对端关闭 通过 WithValidate 设置的检测方法在此之后执行
+func NewConnPool(dial func(ctx context.Context) (net.Conn, error), opts ...Option) (NetPool, error) {
+	cfg := newConfig(nil, func(conn any) error {
+		return conn.(net.Conn).Close()
+	}, opts...)
+	if dial != nil {
+		cfg.FactoryContext = func(ctx context.Context) (interface{}, error) {
+			return dial(ctx)
+		}
+	}
+	validate := cfg.Validate
+	cfg.Validate = func(conn interface{}) error {
+		if err := connCheck(conn.(net.Conn)); err != nil {
+			return err
+		}
+		if validate != nil {
+			return validate(conn)
+		}
+		return nil
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &netPool{pool: p}, nil
+}
+
+//Get 向连接池中获取一个连接
+func (p *netPool) Get(ctx context.Context) (net.Conn, error) {
+	conn, err := p.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn.(net.Conn), nil
+}
+
+//Put 向连接池中放入一个连接
+func (p *netPool) Put(conn net.Conn) error {
+	return p.pool.Put(conn)
+}
+
+//Invalidate 关闭一个已损坏的借出连接 连接读写出错时应调用此方法
+func (p *netPool) Invalidate(conn net.Conn) error {
+	return p.pool.Invalidate(conn)
+}
+
+//Stats 返回连接池当前的运行状态
+func (p *netPool) Stats() Stats {
+	return p.pool.Stats()
+}
+
+//Shutdown 关闭连接池
+func (p *netPool) Shutdown() error {
+	return p.pool.Shutdown()
+}
+
+//deadlineCheck 将读超时设置为当前时间后读取一个字节 检测连接是否已被对端关闭
+//读取超时表示连接正常 对于操作系统连接 超时在系统调用之前就会返回 因此只适用于 net.Pipe 等内存连接
+func deadlineCheck(conn net.Conn) error {
+	if err := conn.SetReadDeadline(time.Now()); err != nil {
+		return err
+	}
+	var buf [1]byte
+	n, err := conn.Read(buf[:])
+	if resetErr := conn.SetReadDeadline(time.Time{}); resetErr != nil {
+		return resetErr
+	}
+	switch {
+	case n > 0:
+		return errUnexpectedRead
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return nil
+	case err == nil:
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
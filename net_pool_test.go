@@ -0,0 +1,68 @@
+package simpleConnPool
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+)
+
+//pipeDialer 使用 net.Pipe 建立连接 并保存服务端一侧
+type pipeDialer struct {
+	mu      sync.Mutex
+	servers []net.Conn
+}
+
+func (d *pipeDialer) dial(context.Context) (net.Conn, error) {
+	client, server := net.Pipe()
+	d.mu.Lock()
+	d.servers = append(d.servers, server)
+	d.mu.Unlock()
+	return client, nil
+}
+
+func TestConnPool(t *testing.T) {
+	d := &pipeDialer{}
+	p, err := NewConnPool(d.dial, WithMaxCap(2))
+	if err != nil {
+		t.Fatalf("NewConnPool: %v", err)
+	}
+
+	ctx := context.Background()
+	conn, err := p.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := p.Put(conn); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	again, err := p.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if again != conn {
+		t.Fatal("healthy idle connection was not reused")
+	}
+	_ = p.Put(again)
+
+	//对端关闭后 借出前检测到连接已失效 创建新的连接
+	_ = d.servers[0].Close()
+	fresh, err := p.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get after peer close: %v", err)
+	}
+	if fresh == conn {
+		t.Fatal("half-closed connection was handed out")
+	}
+	if _, err := conn.Write([]byte("x")); err == nil {
+		t.Fatal("half-closed connection was not closed by the pool")
+	}
+	_ = p.Put(fresh)
+
+	if err := p.Shutdown(); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if _, err := fresh.Write([]byte("x")); err == nil {
+		t.Fatal("Shutdown did not close idle net.Conn")
+	}
+}
@@ -0,0 +1,271 @@
+package simpleConnPool
+
+import (
+	"time"
+)
+
+//默认配置
+const (
+	defaultMaxCap      = 10
+	defaultWaitTimeout = 3 * time.Second
+	defaultWaitQueue   = 100
+)
+
+//optionMask 记录通过 With* 设置过的配置项 newConfig 只为未设置的配置项补全默认值
+//不使用特殊取值表示未设置 任何取值都按原样交给 Config.Check 检查
+type optionMask uint8
+
+const (
+	optMaxCap optionMask = 1 << iota
+	optMaxIdle
+	optWaitTimeout
+	optWaitQueue
+)
+
+//Option 连接池配置项
+type Option func(*Config)
+
+//WithInitialCap 设置连接池中拥有的最小连接数
+func WithInitialCap(n int32) Option {
+	return func(c *Config) { c.InitialCap = n }
+}
+
+//WithMaxCap 设置最大并发存活连接数 n 小于等于0表示不限制
+func WithMaxCap(n int32) Option {
+	return func(c *Config) { c.MaxCap, c.optionsSet = n, c.optionsSet|optMaxCap }
+}
+
+//WithMaxIdle 设置最大空闲连接数
+func WithMaxIdle(n int32) Option {
+	return func(c *Config) { c.MaxIdle, c.optionsSet = n, c.optionsSet|optMaxIdle }
+}
+
+//WithIdleTimeout 设置连接最大空闲时间
+func WithIdleTimeout(d time.Duration) Option {
+	return func(c *Config) { c.IdleTimeout = d }
+}
+
+//WithWaitTimeout 设置获取连接最大等待时间 小于等于0表示一直等待
+func WithWaitTimeout(d time.Duration) Option {
+	return func(c *Config) { c.WaitTimeout, c.optionsSet = d, c.optionsSet|optWaitTimeout }
+}
+
+//WithWaitQueue 设置最大等待请求获取连接数量 n 为0表示不允许等待 需要等待时按 OnQueueFull 处理 未设置时为 100
+func WithWaitQueue(n int32) Option {
+	return func(c *Config) { c.WaitQueue, c.optionsSet = n, c.optionsSet|optWaitQueue }
+}
+
+//WithMinIdle 设置后台维护协程保持的最少空闲连接数
+func WithMinIdle(n int32) Option {
+	return func(c *Config) { c.MinIdle = n }
+}
+
+//WithMaxLifetime 设置连接最大存活时间
+func WithMaxLifetime(d time.Duration) Option {
+	return func(c *Config) { c.MaxLifetime = d }
+}
+
+//WithValidate 设置借出空闲连接前的检测方法
+func WithValidate(validate func(any) error) Option {
+	return func(c *Config) { c.Validate = validate }
+}
+
+//WithMaxValidateFailures 设置单次获取连接中 Validate 失败的最多次数 达到后返回 ErrNoHealthyConnections
+func WithMaxValidateFailures(n int32) Option {
+	return func(c *Config) {
+		c.MaxValidateFailures = n
+	}
+}
+
+//WithValidateOnPut 设置归还连接时也执行检测 检测失败的连接被关闭而不是放回空闲队列
+func WithValidateOnPut() Option {
+	return func(c *Config) {
+		c.ValidateOnPut = true
+	}
+}
+
+//WithReset 设置归还连接时的重置方法 重置失败的连接被关闭而不是放回空闲队列
+func WithReset(reset func(any) error) Option {
+	return func(c *Config) { c.Reset = reset }
+}
+
+//WithLogger 设置日志
+func WithLogger(logger Logger) Option {
+	return func(c *Config) { c.Logger = logger }
+}
+
+//WithLeakDetection 设置连接泄漏检测阈值 stack 为 true 时记录借出连接时的调用栈
+func WithLeakDetection(threshold time.Duration, stack bool) Option {
+	return func(c *Config) {
+		c.LeakThreshold = threshold
+		c.LeakStack = stack
+	}
+}
+
+//WithFactoryRetry 设置创建连接失败后的重试次数与第一次重试前的等待时间
+func WithFactoryRetry(retries int, backoff time.Duration) Option {
+	return func(c *Config) {
+		c.FactoryRetries = retries
+		c.FactoryRetryBackoff = backoff
+	}
+}
+
+//WithCircuitBreaker 设置连接创建熔断器 window 内连续失败 threshold 次后熔断 cooldown 时间
+func WithCircuitBreaker(threshold int32, window, cooldown time.Duration) Option {
+	return func(c *Config) {
+		c.FailureThreshold = threshold
+		c.FailureWindow = window
+		c.CircuitCooldown = cooldown
+	}
+}
+
+//WithStrategy 设置空闲连接的借出顺序
+func WithStrategy(s Strategy) Option {
+	return func(c *Config) {
+		c.Strategy = s
+	}
+}
+
+//WithWarmupAsync 设置在后台协程中创建初始连接 NewPool 不再等待初始化完成
+func WithWarmupAsync() Option {
+	return func(c *Config) {
+		c.WarmupAsync = true
+	}
+}
+
+//WithWarmupTimeout 设置同步初始化连接的最长时间 到期后 NewPool 使用已经创建的连接返回
+func WithWarmupTimeout(d time.Duration) Option {
+	return func(c *Config) {
+		c.WarmupTimeout = d
+	}
+}
+
+//WithMaxUsage 设置连接最多被借出的次数
+func WithMaxUsage(n int32) Option {
+	return func(c *Config) {
+		c.MaxUsage = n
+	}
+}
+
+//WithHealthCheck 设置空闲连接的存活检测方法与检测间隔
+func WithHealthCheck(check func(any) error, interval time.Duration) Option {
+	return func(c *Config) {
+		c.HealthCheck = check
+		c.HealthCheckInterval = interval
+	}
+}
+
+//WithOnQueueFull 设置等待队列已满时的处理方式
+func WithOnQueueFull(policy QueueFullPolicy) Option {
+	return func(c *Config) {
+		c.OnQueueFull = policy
+	}
+}
+
+//WithIdleTimeoutJitter 设置空闲连接超时时间的随机浮动范围 必须小于 IdleTimeout
+func WithIdleTimeoutJitter(d time.Duration) Option {
+	return func(c *Config) {
+		c.IdleTimeoutJitter = d
+	}
+}
+
+//WithEventBuffer 设置 Events 返回的事件 channel 的缓冲大小
+func WithEventBuffer(n int32) Option {
+	return func(c *Config) {
+		c.EventBuffer = n
+	}
+}
+
+//WithShutdownConcurrency 设置 Shutdown 与 DrainContext 并发关闭空闲连接的协程数
+func WithShutdownConcurrency(n int) Option {
+	return func(c *Config) {
+		c.ShutdownConcurrency = n
+	}
+}
+
+//WithKeyFunc 设置从连接得到用于跟踪借出连接的可比较 key 的方法 连接类型不可比较时使用
+func WithKeyFunc(keyFunc func(any) any) Option {
+	return func(c *Config) {
+		c.KeyFunc = keyFunc
+	}
+}
+
+//WithFairGetMany 设置 GetMany 每获取一个连接后让出 与单个获取连接的请求交替获取
+func WithFairGetMany() Option {
+	return func(c *Config) {
+		c.FairGetMany = true
+	}
+}
+
+//WithNoIdle 设置不复用连接 每次 Get 创建新连接 每次 Put 关闭连接 同时将 MaxIdle InitialCap MinIdle 设为0
+func WithNoIdle() Option {
+	return func(c *Config) {
+		c.NoIdle = true
+		c.MaxIdle, c.InitialCap, c.MinIdle = 0, 0, 0
+		c.optionsSet |= optMaxIdle
+	}
+}
+
+//WithIdleOverflow 设置空闲队列最多保存 MaxCap 个连接 MaxIdle 只作为后台维护协程的回收目标
+func WithIdleOverflow() Option {
+	return func(c *Config) {
+		c.IdleOverflow = true
+	}
+}
+
+//WithCreateTimeout 设置每次创建连接的最长时间 超时返回 ErrCreateTimeout
+func WithCreateTimeout(d time.Duration) Option {
+	return func(c *Config) {
+		c.CreateTimeout = d
+	}
+}
+
+//WithPinInitial 设置固定 InitialCap 个连接 不受空闲超时 最大存活时间与最多借出次数回收
+func WithPinInitial() Option {
+	return func(c *Config) {
+		c.PinInitial = true
+	}
+}
+
+//WithBurstCap 设置包含突发连接在内的最大连接数 必须大于 MaxCap
+func WithBurstCap(n int32) Option {
+	return func(c *Config) { c.BurstCap = n }
+}
+
+//NewPoolWithOptions 使用配置项构造连接池
+//未设置的配置项使用默认值: MaxCap 为 10 MaxIdle 与 MaxCap 相同 WaitTimeout 为 3s WaitQueue 为 100
+//WithMaxCap(0) 表示不限制连接数 此时 MaxIdle 默认为 10
+func NewPoolWithOptions(factory func() (any, error), close func(any) error, opts ...Option) (Pool, error) {
+	return NewPool(newConfig(factory, close, opts...))
+}
+
+//newConfig 根据配置项生成连接池配置 并补全未设置的配置项
+func newConfig(factory func() (any, error), close func(any) error, opts ...Option) *Config {
+	cfg := &Config{
+		Factory: factory,
+		Close:   close,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	//MaxCap 小于等于0表示不限制 只有未设置时才使用默认值
+	if cfg.optionsSet&optMaxCap == 0 {
+		cfg.MaxCap = defaultMaxCap
+	}
+	//MaxIdle 为0表示不缓存空闲连接 只有未设置时才使用默认值 不限制 MaxCap 时默认为 10
+	if cfg.optionsSet&optMaxIdle == 0 {
+		cfg.MaxIdle = cfg.MaxCap
+		if cfg.MaxCap <= 0 {
+			cfg.MaxIdle = defaultMaxCap
+		}
+	}
+	//WaitTimeout 为0表示一直等待 只有未设置时才使用默认值
+	if cfg.optionsSet&optWaitTimeout == 0 {
+		cfg.WaitTimeout = defaultWaitTimeout
+	}
+	//WaitQueue 为0表示不允许等待 只有未设置时才使用默认值
+	if cfg.optionsSet&optWaitQueue == 0 {
+		cfg.WaitQueue = defaultWaitQueue
+	}
+	return cfg
+}
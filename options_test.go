@@ -0,0 +1,102 @@
+package simpleConnPool
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewPoolWithOptions(t *testing.T) {
+	factory := func() (any, error) { return &testConn{}, nil }
+	closeFn := func(any) error { return nil }
+
+	p, err := NewPoolWithOptions(factory, closeFn,
+		WithInitialCap(2),
+		WithMaxCap(8),
+		WithMaxIdle(4),
+		WithIdleTimeout(time.Minute),
+		WithWaitTimeout(time.Second),
+		WithWaitQueue(16),
+	)
+	if err != nil {
+		t.Fatalf("NewPoolWithOptions: %v", err)
+	}
+	defer p.Shutdown()
+	want, err := NewPool(&Config{
+		InitialCap:  2,
+		MaxCap:      8,
+		MaxIdle:     4,
+		Factory:     factory,
+		Close:       closeFn,
+		IdleTimeout: time.Minute,
+		WaitTimeout: time.Second,
+		WaitQueue:   16,
+	})
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer want.Shutdown()
+
+	got, exp := p.(*connectionPool), want.(*connectionPool)
+	if got.maxActiveConn != exp.maxActiveConn ||
+		got.maxIdle != exp.maxIdle ||
+		cap(got.waitSlots) != cap(exp.waitSlots) ||
+		got.settings.idleTimeOut != exp.settings.idleTimeOut ||
+		got.settings.waitTimeOut != exp.settings.waitTimeOut ||
+		len(got.idle) != len(exp.idle) {
+		t.Fatalf("options pool differs from config pool: %+v vs %+v", got, exp)
+	}
+}
+
+func TestNewPoolWithOptionsDefaults(t *testing.T) {
+	cfg := newConfig(func() (any, error) { return &testConn{}, nil }, func(any) error { return nil }, WithMaxCap(3))
+	if cfg.MaxCap != 3 || cfg.MaxIdle != 3 {
+		t.Fatalf("MaxCap/MaxIdle = %d/%d, want 3/3", cfg.MaxCap, cfg.MaxIdle)
+	}
+	if cfg.WaitTimeout != defaultWaitTimeout || cfg.WaitQueue != defaultWaitQueue {
+		t.Fatalf("WaitTimeout/WaitQueue = %v/%d, want defaults", cfg.WaitTimeout, cfg.WaitQueue)
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool with defaults: %v", err)
+	}
+	_ = p.Shutdown()
+}
+
+func TestWithMaxIdleZero(t *testing.T) {
+	cfg := newConfig(func() (any, error) { return &testConn{}, nil }, func(any) error { return nil }, WithMaxCap(3), WithMaxIdle(0))
+	if cfg.MaxIdle != 0 {
+		t.Fatalf("MaxIdle = %d, want explicit 0 to be kept", cfg.MaxIdle)
+	}
+}
+
+func TestWithWaitTimeoutZero(t *testing.T) {
+	cfg := newConfig(func() (any, error) { return &testConn{}, nil }, func(any) error { return nil }, WithWaitTimeout(0))
+	if cfg.WaitTimeout != 0 {
+		t.Fatalf("WaitTimeout = %v, want explicit 0 to be kept", cfg.WaitTimeout)
+	}
+}
+
+func TestWithOptionsKeepExplicitValues(t *testing.T) {
+	factory := func() (any, error) { return &testConn{}, nil }
+	closeFn := func(any) error { return nil }
+	//负数不会被当作未设置替换为默认值 而是由 Check 拒绝
+	if _, err := NewPoolWithOptions(factory, closeFn, WithMaxIdle(-1)); !errors.Is(err, InvalidCapSet) {
+		t.Fatalf("WithMaxIdle(-1): got %v, want InvalidCapSet", err)
+	}
+	if _, err := NewPoolWithOptions(factory, closeFn, WithWaitQueue(-1)); !errors.Is(err, InvalidCapSet) {
+		t.Fatalf("WithWaitQueue(-1): got %v, want InvalidCapSet", err)
+	}
+	//WaitQueue 为0表示不允许等待
+	cfg := newConfig(factory, closeFn, WithWaitQueue(0))
+	if cfg.WaitQueue != 0 {
+		t.Fatalf("WaitQueue = %d, want explicit 0 to be kept", cfg.WaitQueue)
+	}
+}
+
+func TestWithMaxCapZero(t *testing.T) {
+	cfg := newConfig(func() (any, error) { return &testConn{}, nil }, func(any) error { return nil }, WithMaxCap(0))
+	if cfg.MaxCap != 0 || cfg.MaxIdle != defaultMaxCap {
+		t.Fatalf("MaxCap/MaxIdle = %d/%d, want 0/%d", cfg.MaxCap, cfg.MaxIdle, defaultMaxCap)
+	}
+}
@@ -1,7 +1,44 @@
 package simpleConnPool
 
+import (
+	"context"
+	"time"
+)
+
 type Pool interface {
 	Get() (any, error)
+	GetContext(ctx context.Context) (any, error)
+	GetWithPriority(ctx context.Context, priority int) (any, error)
+	GetMany(ctx context.Context, n int) ([]any, error)
+	Use(ctx context.Context, fn func(conn any) error) error
+	GetWithInfo(ctx context.Context) (any, GetInfo, error)
+	TryGet() (any, error)
+	GetWithTimeout(d time.Duration) (any, error)
+	GetConn() (*PooledConn, error)
+	GetUncounted() (any, error)
 	Put(any) error
+	CloseConn(any) error
+	//Deprecated: 请使用 CloseConn 关闭单个连接 使用 Shutdown 关闭连接池
 	Close(any) error
+	Invalidate(any) error
+	RefreshConn(old any) (any, error)
+	Shutdown() error
+	DrainContext(ctx context.Context) error
+	ShutdownContext(ctx context.Context) error
+	WaitReady(ctx context.Context) error
+	Ping(ctx context.Context) error
+	IsClosed() bool
+	Stats() Stats
+	StatsSnapshotAndReset() Stats
+	ResetPeak()
+	Events() <-chan Event
+	Len() int
+	IdleLen() int
+	ActiveLen() int
+	Waiters() int
+	Borrowed() []BorrowInfo
+	SetMaxCap(n int32) error
+	SetMaxIdle(n int32) error
+	DrainIdle() error
+	Reconfigure(cfg *Config) error
 }
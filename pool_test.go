@@ -1,7 +1,4427 @@
 package simpleConnPool
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//testConn 测试用连接
+type testConn struct {
+	id int
+}
+
+//newTestConfig 返回一个测试用的连接池配置
+func newTestConfig() *Config {
+	var id int32
+	return &Config{
+		InitialCap:  0,
+		MaxCap:      10,
+		MaxIdle:     5,
+		Factory:     func() (interface{}, error) { return &testConn{id: int(atomic.AddInt32(&id, 1))}, nil },
+		Close:       func(interface{}) error { return nil },
+		IdleTimeout: time.Minute,
+		WaitTimeout: time.Second,
+		WaitQueue:   10,
+	}
+}
 
 func TestNewPool(t *testing.T) {
-	
+	p, err := NewPool(newTestConfig())
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	if p == nil {
+		t.Fatal("NewPool returned nil pool")
+	}
+	_ = p.Shutdown()
+}
+
+func TestGetReturnsOriginalConn(t *testing.T) {
+	cfg := newTestConfig()
+	sentinel := &testConn{id: -1}
+	cfg.Factory = func() (interface{}, error) { return sentinel, nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	created, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := p.Put(created); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	//从空闲队列取出的同样是原始连接
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if conn != sentinel {
+		t.Fatalf("Get returned %#v, want sentinel %#v", conn, sentinel)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.InitialCap = 3
+	var closed int32
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	if err := p.Shutdown(); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if got := atomic.LoadInt32(&closed); got != 3 {
+		t.Fatalf("closed %d idle connections, want 3", got)
+	}
+	if _, err := p.Get(); err != PoolClosed {
+		t.Fatalf("Get after Shutdown: got %v, want PoolClosed", err)
+	}
+	if err := p.Shutdown(); err != nil {
+		t.Fatalf("second Shutdown: %v", err)
+	}
+	if got := atomic.LoadInt32(&closed); got != 3 {
+		t.Fatalf("second Shutdown closed more connections: %d", got)
+	}
+}
+
+func TestShutdownUnblocksWaiters(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	cfg.WaitTimeout = time.Minute
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	if _, err := p.Get(); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := p.Get()
+		errCh <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	_ = p.Shutdown()
+	select {
+	case err := <-errCh:
+		if err != PoolClosed {
+			t.Fatalf("waiter got %v, want PoolClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter was not unblocked by Shutdown")
+	}
+}
+
+func TestGetContextCancel(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	cfg.WaitTimeout = time.Minute
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	held, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := p.GetContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("GetContext: got %v, want context.DeadlineExceeded", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("GetContext did not return promptly after the deadline")
+	}
+
+	//被抛弃的请求不应拿走连接
+	if err := p.Put(held); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if conn != held {
+		t.Fatalf("Get returned %#v, want the returned connection %#v", conn, held)
+	}
+}
+
+func TestStatsConcurrentGetPut(t *testing.T) {
+	const n = 50
+	cfg := newTestConfig()
+	//所有请求都能进入等待队列
+	cfg.WaitQueue = n
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := p.Get()
+			if err != nil {
+				t.Errorf("Get: %v", err)
+				return
+			}
+			time.Sleep(time.Millisecond)
+			if err := p.Put(conn); err != nil {
+				t.Errorf("Put: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := p.Stats()
+	if stats.TotalGets != n {
+		t.Fatalf("TotalGets = %d, want %d", stats.TotalGets, n)
+	}
+	if stats.ActiveCount != 0 {
+		t.Fatalf("ActiveCount = %d, want 0", stats.ActiveCount)
+	}
+}
+
+func TestGetTimeoutDoesNotLeak(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	cfg.WaitTimeout = time.Millisecond
+	//被抛弃的请求仍会占用等待队列
+	cfg.WaitQueue = 500
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	if _, err := p.Get(); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 200; i++ {
+		if _, err := p.Get(); !errors.Is(err, GetConnectionTimeout) {
+			t.Fatalf("Get: got %v, want GetConnectionTimeout", err)
+		}
+	}
+	if after := runtime.NumGoroutine(); after > before+5 {
+		t.Fatalf("goroutines grew from %d to %d", before, after)
+	}
+}
+
+func TestGetCreatesUpToMaxCap(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 3
+	cfg.MaxIdle = 3
+	cfg.WaitTimeout = 50 * time.Millisecond
+	var created int32
+	factory := cfg.Factory
+	cfg.Factory = func() (interface{}, error) {
+		atomic.AddInt32(&created, 1)
+		return factory()
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.Get(); err != nil {
+				t.Errorf("Get: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := atomic.LoadInt32(&created); got != 3 {
+		t.Fatalf("factory called %d times, want 3", got)
+	}
+	//第四个请求只能进入等待队列
+	if _, err := p.Get(); !errors.Is(err, GetConnectionTimeout) {
+		t.Fatalf("fourth Get: got %v, want GetConnectionTimeout", err)
+	}
+	if got := atomic.LoadInt32(&created); got != 3 {
+		t.Fatalf("fourth Get created a connection beyond MaxCap")
+	}
+}
+
+func TestFactoryErrorReleasesCapacity(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 3
+	cfg.MaxIdle = 3
+	cfg.WaitTimeout = 10 * time.Millisecond
+	const failures = 5
+	var calls int32
+	factory := cfg.Factory
+	errDial := errors.New("dial failed")
+	cfg.Factory = func() (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			return nil, errDial
+		}
+		return factory()
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	for i := 0; i < failures; i++ {
+		if _, err := p.Get(); err != errDial {
+			t.Fatalf("Get #%d: got %v, want the factory error", i, err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := p.Get(); err != nil {
+			t.Fatalf("Get after failures #%d: %v", i, err)
+		}
+	}
+	if got := p.Stats().TotalFactoryErrors; got != failures {
+		t.Fatalf("TotalFactoryErrors = %d, want %d", got, failures)
+	}
+}
+
+func TestMaxLifetime(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxLifetime = 30 * time.Millisecond
+	var closed int32
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	//归还时已超过最大存活时间的连接被直接关闭
+	old, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := p.Put(old); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got := atomic.LoadInt32(&closed); got != 1 {
+		t.Fatalf("closed %d connections on Put, want 1", got)
+	}
+	if got := p.Stats().IdleCount; got != 0 {
+		t.Fatalf("IdleCount = %d, want 0", got)
+	}
+
+	//空闲期间超过最大存活时间的连接在 Get 时被替换
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := p.Put(conn); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	fresh, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if fresh == conn {
+		t.Fatal("Get returned a connection older than MaxLifetime")
+	}
+	if got := atomic.LoadInt32(&closed); got != 2 {
+		t.Fatalf("closed %d connections, want 2", got)
+	}
+}
+
+func TestReapIdle(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.InitialCap = 2
+	cfg.IdleTimeout = 20 * time.Millisecond
+	cfg.MaintainInterval = 5 * time.Millisecond
+	var closed int32
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	conns := make([]any, 0, 5)
+	for i := 0; i < 5; i++ {
+		conn, err := p.Get()
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		conns = append(conns, conn)
+	}
+	for _, conn := range conns {
+		if err := p.Put(conn); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	if got := p.Stats().IdleCount; got != 5 {
+		t.Fatalf("IdleCount = %d, want 5", got)
+	}
+
+	//无需调用 Get 超时的空闲连接也会被回收 但保留 InitialCap 个 保留的连接超时后被新连接替换
+	reaped := func() bool {
+		s := p.Stats()
+		return atomic.LoadInt32(&closed) >= 5 && s.IdleCount == 2 && s.OpeningConn == 2
+	}
+	deadline := time.Now().Add(time.Second)
+	for !reaped() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if !reaped() {
+		s := p.Stats()
+		t.Fatalf("closed %d connections with IdleCount/OpeningConn = %d/%d, want at least 5 closed and 2/2",
+			atomic.LoadInt32(&closed), s.IdleCount, s.OpeningConn)
+	}
+}
+
+func TestTryGet(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 2
+	cfg.MaxIdle = 2
+	cfg.IdleTimeout = 20 * time.Millisecond
+	cfg.MaintainInterval = time.Hour
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	//新建连接
+	first, err := p.TryGet()
+	if err != nil {
+		t.Fatalf("TryGet create: %v", err)
+	}
+	//空闲连接命中
+	if err := p.Put(first); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	conn, err := p.TryGet()
+	if err != nil {
+		t.Fatalf("TryGet idle: %v", err)
+	}
+	if conn != first {
+		t.Fatalf("TryGet returned %#v, want idle connection %#v", conn, first)
+	}
+	//超过空闲时间的连接不会被返回
+	if err := p.Put(conn); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	second, err := p.TryGet()
+	if err != nil {
+		t.Fatalf("TryGet after idle timeout: %v", err)
+	}
+	if second == first {
+		t.Fatal("TryGet returned an idle-timed-out connection")
+	}
+	//连接池耗尽
+	if _, err := p.TryGet(); err != nil {
+		t.Fatalf("TryGet: %v", err)
+	}
+	start := time.Now()
+	if _, err := p.TryGet(); err != ErrPoolExhausted {
+		t.Fatalf("TryGet: got %v, want ErrPoolExhausted", err)
+	}
+	if time.Since(start) > 10*time.Millisecond {
+		t.Fatal("TryGet blocked on an exhausted pool")
+	}
+}
+
+func TestValidateRejectsIdleConn(t *testing.T) {
+	cfg := newTestConfig()
+	var closed int32
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	var bad atomic.Value
+	cfg.Validate = func(conn interface{}) error {
+		if conn == bad.Load() {
+			return errors.New("broken")
+		}
+		return nil
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	broken, _ := p.Get()
+	bad.Store(broken)
+	good, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := p.Put(broken); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := p.Put(good); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if conn != good {
+		t.Fatalf("Get returned %#v, want the valid connection %#v", conn, good)
+	}
+	if got := atomic.LoadInt32(&closed); got != 1 {
+		t.Fatalf("closed %d connections, want the rejected one closed", got)
+	}
+}
+
+func TestLenAccessors(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.InitialCap = 2
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	check := func(step string, total, idle, active int) {
+		t.Helper()
+		if p.Len() != total || p.IdleLen() != idle || p.ActiveLen() != active {
+			t.Fatalf("%s: Len/IdleLen/ActiveLen = %d/%d/%d, want %d/%d/%d",
+				step, p.Len(), p.IdleLen(), p.ActiveLen(), total, idle, active)
+		}
+	}
+	check("init", 2, 2, 0)
+	a, _ := p.Get()
+	b, _ := p.Get()
+	c, _ := p.Get()
+	check("borrow", 3, 0, 3)
+	_ = p.Put(a)
+	check("return one", 3, 1, 2)
+	_ = p.Put(b)
+	_ = p.Put(c)
+	check("return all", 3, 3, 0)
+}
+
+func TestShutdownMidFlight(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.WaitTimeout = 10 * time.Millisecond
+	var created, closed int32
+	factory := cfg.Factory
+	cfg.Factory = func() (interface{}, error) {
+		atomic.AddInt32(&created, 1)
+		return factory()
+	}
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				conn, err := p.Get()
+				if err == PoolClosed {
+					return
+				}
+				if err != nil {
+					continue
+				}
+				time.Sleep(time.Millisecond)
+				if err := p.Put(conn); err == PoolClosed {
+					return
+				}
+			}
+		}()
+	}
+	time.Sleep(30 * time.Millisecond)
+	if err := p.Shutdown(); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if !p.IsClosed() {
+		t.Fatal("IsClosed = false after Shutdown")
+	}
+	wg.Wait()
+
+	if _, err := p.TryGet(); err != PoolClosed {
+		t.Fatalf("TryGet after Shutdown: got %v, want PoolClosed", err)
+	}
+	if c, d := atomic.LoadInt32(&created), atomic.LoadInt32(&closed); c != d {
+		t.Fatalf("created %d connections but closed %d", c, d)
+	}
+}
+
+func TestPutAfterShutdownClosesConn(t *testing.T) {
+	cfg := newTestConfig()
+	var closed int32
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	_ = p.Shutdown()
+	if err := p.Put(conn); err != PoolClosed {
+		t.Fatalf("Put after Shutdown: got %v, want PoolClosed", err)
+	}
+	if got := atomic.LoadInt32(&closed); got != 1 {
+		t.Fatalf("Put after Shutdown closed %d connections, want 1", got)
+	}
+}
+
+func TestPutDuringShutdown(t *testing.T) {
+	for round := 0; round < 20; round++ {
+		cfg := newTestConfig()
+		cfg.MaxCap = 32
+		cfg.MaxIdle = 8
+		var created, closed int32
+		factory := cfg.Factory
+		cfg.Factory = func() (interface{}, error) { atomic.AddInt32(&created, 1); return factory() }
+		cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+		p, err := NewPool(cfg)
+		if err != nil {
+			t.Fatalf("NewPool: %v", err)
+		}
+		//订阅事件 使 Put 发布事件与 Shutdown 关闭事件 channel 并发
+		go func() {
+			for range p.Events() {
+			}
+		}()
+		conns := make([]any, 0, cfg.MaxCap)
+		for i := int32(0); i < cfg.MaxCap; i++ {
+			conn, err := p.Get()
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			conns = append(conns, conn)
+		}
+
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for _, conn := range conns {
+			wg.Add(1)
+			go func(conn any) {
+				defer wg.Done()
+				<-start
+				if err := p.Put(conn); err != nil && err != PoolClosed {
+					t.Errorf("Put: %v", err)
+				}
+			}(conn)
+		}
+		close(start)
+		_ = p.Shutdown()
+		wg.Wait()
+
+		if c, d := atomic.LoadInt32(&created), atomic.LoadInt32(&closed); c != d {
+			t.Fatalf("round %d: created %d connections but closed %d", round, c, d)
+		}
+	}
+}
+
+func TestSetMaxCap(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 3
+	cfg.MaxIdle = 1
+	cfg.WaitTimeout = time.Second
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	//调小: 已借出的连接不受影响 但不再创建超出新上限的连接
+	a, _ := p.Get()
+	b, _ := p.Get()
+	if err := p.SetMaxCap(2); err != nil {
+		t.Fatalf("SetMaxCap: %v", err)
+	}
+	if _, err := p.TryGet(); err != ErrPoolExhausted {
+		t.Fatalf("TryGet after shrink: got %v, want ErrPoolExhausted", err)
+	}
+	if err := p.SetMaxCap(1); err != nil {
+		t.Fatalf("SetMaxCap: %v", err)
+	}
+	if err := p.Put(a); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if p.Len() != 2 {
+		t.Fatalf("Len = %d, want 2: shrinking must not close live connections", p.Len())
+	}
+
+	//调大: 等待中的请求与新的请求都可以创建连接
+	if _, err := p.Get(); err != nil {
+		t.Fatalf("Get idle: %v", err)
+	}
+	got := make(chan error, 1)
+	go func() {
+		_, err := p.Get()
+		got <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := p.SetMaxCap(4); err != nil {
+		t.Fatalf("SetMaxCap: %v", err)
+	}
+	select {
+	case err := <-got:
+		if err != nil {
+			t.Fatalf("waiter after grow: %v", err)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("waiter was not served after growing MaxCap")
+	}
+	if _, err := p.TryGet(); err != nil {
+		t.Fatalf("TryGet after grow: %v", err)
+	}
+	_ = p.Put(b)
+
+	//MaxCap 为0表示不限制 不再需要等待
+	if err := p.SetMaxCap(0); err != nil {
+		t.Fatalf("SetMaxCap(0): %v", err)
+	}
+	if _, err := p.TryGet(); err != nil {
+		t.Fatalf("TryGet after unbounding: %v", err)
+	}
+
+	//不能小于 MaxIdle 连接池关闭后不能再调整
+	if err := p.SetMaxIdle(2); err != nil {
+		t.Fatalf("SetMaxIdle: %v", err)
+	}
+	if err := p.SetMaxCap(1); err != InvalidCapSet {
+		t.Fatalf("SetMaxCap below MaxIdle: got %v, want InvalidCapSet", err)
+	}
+	_ = p.Shutdown()
+	if err := p.SetMaxCap(4); err != PoolClosed {
+		t.Fatalf("SetMaxCap after Shutdown: got %v, want PoolClosed", err)
+	}
+}
+
+func TestSetMaxIdle(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxIdle = 2
+	var closed int32
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	conns := make([]any, 0, 4)
+	for i := 0; i < 4; i++ {
+		conn, _ := p.Get()
+		conns = append(conns, conn)
+	}
+	//调大: 可以保留更多空闲连接
+	if err := p.SetMaxIdle(4); err != nil {
+		t.Fatalf("SetMaxIdle: %v", err)
+	}
+	for _, conn := range conns {
+		_ = p.Put(conn)
+	}
+	if p.IdleLen() != 4 || atomic.LoadInt32(&closed) != 0 {
+		t.Fatalf("IdleLen = %d closed = %d, want 4 idle and none closed", p.IdleLen(), closed)
+	}
+	//调小: 关闭超出的空闲连接 保留最近放入的
+	if err := p.SetMaxIdle(1); err != nil {
+		t.Fatalf("SetMaxIdle: %v", err)
+	}
+	if p.IdleLen() != 1 || atomic.LoadInt32(&closed) != 3 {
+		t.Fatalf("IdleLen = %d closed = %d, want 1 idle and 3 closed", p.IdleLen(), closed)
+	}
+	if conn, _ := p.Get(); conn != conns[3] {
+		t.Fatalf("kept idle connection %#v, want the most recently returned %#v", conn, conns[3])
+	}
+}
+
+func TestSetMaxIdleInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		n      int32
+		want   error
+	}{
+		{"negative", func(*Config) {}, -1, InvalidCapSet},
+		{"above MaxCap", func(*Config) {}, 11, InvalidCapSet},
+		{"NoIdle", func(c *Config) { c.NoIdle, c.MaxIdle = true, 0 }, 1, InvalidCapSet},
+		{"IdleOverflow", func(c *Config) { c.IdleOverflow = true }, 0, InvalidCapSet},
+		{"unbounded MaxCap", func(c *Config) { c.MaxCap = 0 }, 100, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			tt.modify(cfg)
+			p, err := NewPool(cfg)
+			if err != nil {
+				t.Fatalf("NewPool: %v", err)
+			}
+			defer p.Shutdown()
+			if err := p.SetMaxIdle(tt.n); err != tt.want {
+				t.Fatalf("SetMaxIdle(%d): got %v, want %v", tt.n, err, tt.want)
+			}
+		})
+	}
+
+	p, err := NewPool(newTestConfig())
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	_ = p.Shutdown()
+	if err := p.SetMaxIdle(1); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("SetMaxIdle after Shutdown: got %v, want ErrPoolClosed", err)
+	}
+}
+
+func TestSetMaxIdleBelowPinned(t *testing.T) {
+	clk := newFakeClock()
+	cfg := newTestConfig()
+	cfg.InitialCap = 3
+	cfg.MaxIdle = 4
+	cfg.PinInitial = true
+	cfg.MaintainInterval = 10 * time.Second
+	cfg.clock = clk
+	var created, closed int32
+	factory := cfg.Factory
+	cfg.Factory = func() (interface{}, error) { atomic.AddInt32(&created, 1); return factory() }
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	waitFor(t, func() bool { return clk.tickers() == 1 })
+
+	conns := make([]any, 4)
+	for i := range conns {
+		conns[i], _ = p.Get()
+	}
+	for _, conn := range conns {
+		clk.Advance(time.Second)
+		_ = p.Put(conn)
+	}
+	//优先关闭非固定连接 即使它最近才归还
+	if err := p.SetMaxIdle(3); err != nil {
+		t.Fatalf("SetMaxIdle: %v", err)
+	}
+	if n := atomic.LoadInt32(&closed); n != 1 {
+		t.Fatalf("closed %d connections, want only the unpinned one", n)
+	}
+	//容量小于固定连接数时关闭最早的固定连接 其余连接保持原来的顺序
+	if err := p.SetMaxIdle(2); err != nil {
+		t.Fatalf("SetMaxIdle: %v", err)
+	}
+	//固定连接数不再超过 MaxIdle 后台维护协程不会反复创建又关闭
+	//维护协程接收下一次触发时上一次的维护已经完成 因此每次前进后等待触发被接收
+	for i := 0; i < 6; i++ {
+		clk.Advance(10 * time.Second)
+		waitFor(t, func() bool { return clk.pending() == 0 })
+	}
+	if c, d := atomic.LoadInt32(&created), atomic.LoadInt32(&closed); c != 4 || d != 2 {
+		t.Fatalf("created/closed %d/%d connections, want 4/2", c, d)
+	}
+	for _, want := range conns[1:3] {
+		if conn, _ := p.Get(); conn != want {
+			t.Fatalf("Get = %v, want %v", conn, want)
+		}
+	}
+}
+
+//captureLogger 记录所有日志的测试用 Logger
+type captureLogger struct {
+	mu     sync.Mutex
+	debugs []string
+	warns  []string
+	errors []string
+}
+
+func (l *captureLogger) Debugf(format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.debugs = append(l.debugs, fmt.Sprintf(format, args...))
+}
+
+func (l *captureLogger) Warnf(format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warns = append(l.warns, fmt.Sprintf(format, args...))
+}
+
+func (l *captureLogger) Errorf(format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errors = append(l.errors, fmt.Sprintf(format, args...))
+}
+
+func (l *captureLogger) counts() (debugs, warns, errors int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.debugs), len(l.warns), len(l.errors)
+}
+
+func TestLoggerFactoryError(t *testing.T) {
+	cfg := newTestConfig()
+	logger := &captureLogger{}
+	cfg.Logger = logger
+	errDial := errors.New("dial failed")
+	cfg.Factory = func() (interface{}, error) { return nil, errDial }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	if _, err := p.Get(); err != errDial {
+		t.Fatalf("Get: got %v, want the factory error", err)
+	}
+	if _, _, errs := logger.counts(); errs != 1 {
+		t.Fatalf("got %d error logs, want exactly 1: %v", errs, logger.errors)
+	}
+	if !strings.Contains(logger.errors[0], errDial.Error()) {
+		t.Fatalf("error log %q does not mention the factory error", logger.errors[0])
+	}
+}
+
+func TestLoggerTimeoutAndShutdown(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	cfg.WaitTimeout = 5 * time.Millisecond
+	logger := &captureLogger{}
+	cfg.Logger = logger
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	_, _ = p.Get()
+	if _, err := p.Get(); !errors.Is(err, GetConnectionTimeout) {
+		t.Fatalf("Get: got %v, want GetConnectionTimeout", err)
+	}
+	_ = p.Shutdown()
+	if debugs, warns, _ := logger.counts(); warns != 1 || debugs != 1 {
+		t.Fatalf("got %d warn and %d debug logs, want 1 timeout warning and 1 shutdown debug", warns, debugs)
+	}
+}
+
+func TestLifecycleHooks(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxIdle = 1
+	var gets, puts, closes int32
+	cfg.OnGet = func(interface{}) { atomic.AddInt32(&gets, 1) }
+	cfg.OnPut = func(interface{}) { atomic.AddInt32(&puts, 1) }
+	errClose := errors.New("close failed")
+	cfg.Close = func(interface{}) error { return errClose }
+	cfg.OnClose = func(_ interface{}, err error) {
+		if err != errClose {
+			t.Errorf("OnClose got err %v, want the close error", err)
+		}
+		atomic.AddInt32(&closes, 1)
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+
+	a, _ := p.Get()
+	b, _ := p.Get()
+	_ = p.Put(a)
+	_ = p.CloseConn(b)
+	a, _ = p.Get()
+	_ = p.Put(a)
+	_ = p.Shutdown()
+
+	if gets != 3 || puts != 2 || closes != 2 {
+		t.Fatalf("OnGet/OnPut/OnClose called %d/%d/%d times, want 3/2/2", gets, puts, closes)
+	}
+}
+
+func TestDrainContext(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.InitialCap = 2
+	var closed int32
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	drained := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		drained <- p.DrainContext(ctx)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if _, err := p.Get(); err != PoolClosed {
+		t.Fatalf("Get during drain: got %v, want PoolClosed", err)
+	}
+	select {
+	case err := <-drained:
+		t.Fatalf("DrainContext returned %v before the borrowed connection came back", err)
+	default:
+	}
+	_ = p.Put(conn)
+	if err := <-drained; err != nil {
+		t.Fatalf("DrainContext: %v", err)
+	}
+	if got := atomic.LoadInt32(&closed); got != 2 {
+		t.Fatalf("closed %d connections, want 2", got)
+	}
+}
+
+func TestDrainContextTimeout(t *testing.T) {
+	p, err := NewPool(newTestConfig())
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	if _, err := p.Get(); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if err := p.DrainContext(ctx); err != ErrDrainTimeout {
+		t.Fatalf("DrainContext: got %v, want ErrDrainTimeout", err)
+	}
+}
+
+func TestShutdownContextForcesClose(t *testing.T) {
+	cfg := newTestConfig()
+	closed := make(chan any, 2)
+	cfg.Close = func(conn interface{}) error { closed <- conn; return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	returned, _ := p.Get()
+	leaked, _ := p.Get()
+	//在截止时间前归还的连接被正常关闭
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = p.Put(returned)
+	}()
+
+	const grace = 50 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	start := time.Now()
+	err = p.ShutdownContext(ctx)
+	if !errors.Is(err, ErrForcedShutdown) {
+		t.Fatalf("ShutdownContext: got %v, want ErrForcedShutdown", err)
+	}
+	if d := time.Since(start); d < grace {
+		t.Fatalf("ShutdownContext returned after %v, before the %v deadline", d, grace)
+	}
+	for _, want := range []any{returned, leaked} {
+		select {
+		case conn := <-closed:
+			if conn != want {
+				t.Fatalf("closed %v, want %v", conn, want)
+			}
+		default:
+			t.Fatalf("connection %v was not closed", want)
+		}
+	}
+	if s := p.Stats(); s.ActiveCount != 0 || s.OpeningConn != 0 {
+		t.Fatalf("ActiveCount/OpeningConn = %d/%d, want 0/0", s.ActiveCount, s.OpeningConn)
+	}
+	if err := p.Put(leaked); err != ErrUnknownConnection {
+		t.Fatalf("Put after force close: got %v, want ErrUnknownConnection", err)
+	}
+}
+
+func TestGetWithTimeout(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	cfg.WaitTimeout = 10 * time.Millisecond
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	//自定义超时比默认值更长 在超时前等到归还的连接
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = p.Put(conn)
+	}()
+	got, err := p.GetWithTimeout(time.Second)
+	if err != nil {
+		t.Fatalf("GetWithTimeout: %v", err)
+	}
+	if got != conn {
+		t.Fatalf("GetWithTimeout returned %#v, want %#v", got, conn)
+	}
+
+	//自定义超时触发
+	start := time.Now()
+	if _, err := p.GetWithTimeout(30 * time.Millisecond); !errors.Is(err, GetConnectionTimeout) {
+		t.Fatalf("GetWithTimeout: got %v, want GetConnectionTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Fatalf("GetWithTimeout returned after %v, before the custom timeout", elapsed)
+	}
+	//负数不等待
+	if _, err := p.GetWithTimeout(-1); err != ErrPoolExhausted {
+		t.Fatalf("GetWithTimeout(-1): got %v, want ErrPoolExhausted", err)
+	}
+}
+
+func TestMinIdleRefill(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 4
+	cfg.MaxIdle = 4
+	cfg.MinIdle = 2
+	cfg.MaintainInterval = 5 * time.Millisecond
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	waitIdle := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for p.IdleLen() != want && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if got := p.IdleLen(); got != want {
+			t.Fatalf("IdleLen = %d, want %d", got, want)
+		}
+	}
+	waitIdle(2)
+	//借空连接池后空闲连接会被补充
+	a, _ := p.Get()
+	b, _ := p.Get()
+	waitIdle(2)
+	//补充连接不会超过 MaxCap
+	c, _ := p.Get()
+	d, _ := p.Get()
+	time.Sleep(20 * time.Millisecond)
+	if p.Len() != 4 || p.IdleLen() != 0 {
+		t.Fatalf("Len/IdleLen = %d/%d, want 4/0", p.Len(), p.IdleLen())
+	}
+	for _, conn := range []any{a, b, c, d} {
+		_ = p.Put(conn)
+	}
+}
+
+func TestPutOverflowOpeningConn(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 6
+	cfg.MaxIdle = 2
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	for round := 0; round < 20; round++ {
+		conns := make([]any, 0, 6)
+		for i := 0; i < 6; i++ {
+			conn, err := p.Get()
+			if err != nil {
+				t.Fatalf("round %d Get: %v", round, err)
+			}
+			conns = append(conns, conn)
+		}
+		for _, conn := range conns {
+			_ = p.Put(conn)
+			stats := p.Stats()
+			if stats.OpeningConn < 0 {
+				t.Fatalf("round %d: OpeningConn went negative: %d", round, stats.OpeningConn)
+			}
+			if int(stats.OpeningConn) != p.Len() {
+				t.Fatalf("round %d: OpeningConn = %d, want live count %d", round, stats.OpeningConn, p.Len())
+			}
+		}
+	}
+}
+
+func TestPutRejectsUnknownConn(t *testing.T) {
+	p, err := NewPool(newTestConfig())
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	//非本连接池借出的连接
+	if err := p.Put(&testConn{id: -1}); err != ErrUnknownConnection {
+		t.Fatalf("Put foreign: got %v, want ErrUnknownConnection", err)
+	}
+	//重复归还
+	conn, _ := p.Get()
+	if err := p.Put(conn); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := p.Put(conn); err != ErrUnknownConnection {
+		t.Fatalf("second Put: got %v, want ErrUnknownConnection", err)
+	}
+	stats := p.Stats()
+	if stats.OpeningConn != 1 || stats.ActiveCount != 0 || stats.IdleCount != 1 {
+		t.Fatalf("accounting corrupted: %+v", stats)
+	}
+}
+
+func TestLeakDetection(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.LeakThreshold = 20 * time.Millisecond
+	cfg.LeakStack = true
+	cfg.MaintainInterval = 5 * time.Millisecond
+	logger := &captureLogger{}
+	cfg.Logger = logger
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	held, _ := p.Get()
+	returned, _ := p.Get()
+	_ = p.Put(returned)
+	time.Sleep(80 * time.Millisecond)
+
+	_, warns, _ := logger.counts()
+	if warns != 1 {
+		t.Fatalf("got %d leak warnings, want exactly 1: %v", warns, logger.warns)
+	}
+	if !strings.Contains(logger.warns[0], "TestLeakDetection") {
+		t.Fatalf("leak warning does not include the borrow stack: %s", logger.warns[0])
+	}
+	_ = p.Put(held)
+}
+
+func TestFactoryRetry(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.FactoryRetries = 2
+	cfg.FactoryRetryBackoff = 5 * time.Millisecond
+	var calls int32
+	factory := cfg.Factory
+	cfg.Factory = func() (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			return nil, errors.New("dial failed")
+		}
+		return factory()
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	if _, err := p.Get(); err != nil {
+		t.Fatalf("Get with retries: %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Fatalf("factory called %d times, want 3", got)
+	}
+	if got := p.Stats().OpeningConn; got != 1 {
+		t.Fatalf("OpeningConn = %d, want 1", got)
+	}
+}
+
+func TestFactoryRetryRespectsContext(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.FactoryRetries = 5
+	cfg.FactoryRetryBackoff = time.Second
+	cfg.Factory = func() (interface{}, error) { return nil, errors.New("dial failed") }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.GetContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("GetContext: got %v, want context.DeadlineExceeded", err)
+	}
+	if got := p.Stats().OpeningConn; got != 0 {
+		t.Fatalf("OpeningConn = %d, want 0", got)
+	}
+}
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.FailureThreshold = 3
+	cfg.CircuitCooldown = time.Minute
+	var calls int32
+	cfg.Factory = func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, errors.New("dial failed")
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	for i := 0; i < 3; i++ {
+		if _, err := p.Get(); err == nil || err == ErrCircuitOpen {
+			t.Fatalf("Get %d: got %v, want factory error", i, err)
+		}
+	}
+	if got := p.Stats().Circuit; got != CircuitOpen {
+		t.Fatalf("Circuit = %v, want open", got)
+	}
+	if _, err := p.Get(); err != ErrCircuitOpen {
+		t.Fatalf("Get while open: got %v, want ErrCircuitOpen", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Fatalf("factory called %d times while open, want 3", got)
+	}
+	stats := p.Stats()
+	if stats.OpeningConn != 0 || stats.TotalFactoryErrors != 3 {
+		t.Fatalf("OpeningConn = %d TotalFactoryErrors = %d, want 0 and 3", stats.OpeningConn, stats.TotalFactoryErrors)
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.FailureThreshold = 2
+	cfg.CircuitCooldown = 30 * time.Millisecond
+	var failing int32 = 1
+	factory := cfg.Factory
+	cfg.Factory = func() (interface{}, error) {
+		if atomic.LoadInt32(&failing) == 1 {
+			return nil, errors.New("dial failed")
+		}
+		return factory()
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	_, _ = p.Get()
+	_, _ = p.Get()
+	if got := p.Stats().Circuit; got != CircuitOpen {
+		t.Fatalf("Circuit = %v, want open", got)
+	}
+
+	//冷却结束后试探失败 重新熔断
+	time.Sleep(40 * time.Millisecond)
+	if _, err := p.Get(); err == nil || err == ErrCircuitOpen {
+		t.Fatalf("probe Get: got %v, want factory error", err)
+	}
+	if _, err := p.Get(); err != ErrCircuitOpen {
+		t.Fatalf("Get after failed probe: got %v, want ErrCircuitOpen", err)
+	}
+
+	//后端恢复 冷却结束后试探成功 关闭熔断
+	atomic.StoreInt32(&failing, 0)
+	time.Sleep(40 * time.Millisecond)
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatalf("probe Get after recovery: %v", err)
+	}
+	if got := p.Stats().Circuit; got != CircuitClosed {
+		t.Fatalf("Circuit = %v, want closed", got)
+	}
+	if _, err := p.Get(); err != nil {
+		t.Fatalf("Get after recovery: %v", err)
+	}
+	_ = p.Put(conn)
+}
+
+func TestCircuitBreakerFailureWindow(t *testing.T) {
+	b := newCircuitBreaker(2, 20*time.Millisecond, time.Minute, nopLogger{}, realClock{})
+	b.failure()
+	time.Sleep(30 * time.Millisecond)
+	b.failure()
+	if got := b.currentState(); got != CircuitClosed {
+		t.Fatalf("failures outside the window opened the circuit: %v", got)
+	}
+	b.failure()
+	if got := b.currentState(); got != CircuitOpen {
+		t.Fatalf("Circuit = %v, want open", got)
+	}
+}
+
+func TestLIFOAgesOutColdConns(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Strategy = LIFO
+	cfg.IdleTimeout = 50 * time.Millisecond
+	cfg.MaintainInterval = 10 * time.Millisecond
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	conns := make([]interface{}, 5)
+	for i := range conns {
+		if conns[i], err = p.Get(); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+	}
+	for _, conn := range conns {
+		_ = p.Put(conn)
+	}
+
+	//持续借出归还 LIFO 下只有最近归还的连接保持活跃
+	hot := conns[len(conns)-1]
+	deadline := time.Now().Add(150 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		conn, err := p.Get()
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if conn != hot {
+			t.Fatalf("LIFO returned %v, want most recently returned %v", conn, hot)
+		}
+		_ = p.Put(conn)
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := p.IdleLen(); got != 1 {
+		t.Fatalf("IdleLen = %d, want 1 after cold connections aged out", got)
+	}
+}
+
+//BenchmarkGetIdleHit 对比无竞争且命中空闲连接时 Get 的快速路径与 GetWithPriority 的完整路径
+func BenchmarkGetIdleHit(b *testing.B) {
+	for _, s := range []struct {
+		name string
+		get  func(p Pool) (any, error)
+	}{
+		{"FastPath", func(p Pool) (any, error) { return p.Get() }},
+		{"FullPath", func(p Pool) (any, error) { return p.GetWithPriority(context.Background(), 0) }},
+	} {
+		b.Run(s.name, func(b *testing.B) {
+			p, err := NewPool(newTestConfig())
+			if err != nil {
+				b.Fatalf("NewPool: %v", err)
+			}
+			defer p.Shutdown()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				conn, err := s.get(p)
+				if err != nil {
+					b.Fatalf("Get: %v", err)
+				}
+				_ = p.Put(conn)
+			}
+		})
+	}
+}
+
+func BenchmarkGetPut(b *testing.B) {
+	for _, s := range []struct {
+		name     string
+		strategy Strategy
+	}{{"FIFO", FIFO}, {"LIFO", LIFO}} {
+		b.Run(s.name, func(b *testing.B) {
+			cfg := newTestConfig()
+			cfg.Strategy = s.strategy
+			p, err := NewPool(cfg)
+			if err != nil {
+				b.Fatalf("NewPool: %v", err)
+			}
+			defer p.Shutdown()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					conn, err := p.Get()
+					if err != nil {
+						b.Errorf("Get: %v", err)
+						return
+					}
+					_ = p.Put(conn)
+				}
+			})
+		})
+	}
+}
+
+func TestWarmupAsync(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.InitialCap = 3
+	cfg.WarmupAsync = true
+	var calls int32
+	factory := cfg.Factory
+	cfg.Factory = func() (interface{}, error) {
+		time.Sleep(30 * time.Millisecond)
+		//第一个连接创建失败 不影响连接池构造
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return nil, errors.New("dial failed")
+		}
+		return factory()
+	}
+	start := time.Now()
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Fatalf("NewPool blocked for %v with WarmupAsync", elapsed)
+	}
+
+	short, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.WaitReady(short); err != context.DeadlineExceeded {
+		t.Fatalf("WaitReady before warm-up finished: got %v, want context.DeadlineExceeded", err)
+	}
+	if err := p.WaitReady(context.Background()); err != nil {
+		t.Fatalf("WaitReady: %v", err)
+	}
+	if got := p.IdleLen(); got != 2 {
+		t.Fatalf("IdleLen = %d, want 2 after one failed warm-up connection", got)
+	}
+	if got := p.Stats().OpeningConn; got != 2 {
+		t.Fatalf("OpeningConn = %d, want 2", got)
+	}
+}
+
+func TestMaxUsage(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxUsage = 3
+	var closed int32
+	cfg.Close = func(interface{}) error {
+		atomic.AddInt32(&closed, 1)
+		return nil
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	first, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	_ = p.Put(first)
+	for i := 2; i <= 3; i++ {
+		conn, err := p.Get()
+		if err != nil {
+			t.Fatalf("Get %d: %v", i, err)
+		}
+		if conn != first {
+			t.Fatalf("borrow %d returned %v, want reused %v", i, conn, first)
+		}
+		_ = p.Put(conn)
+	}
+	if got := atomic.LoadInt32(&closed); got != 1 {
+		t.Fatalf("closed %d connections, want 1 after MaxUsage borrows", got)
+	}
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get 4: %v", err)
+	}
+	if conn == first {
+		t.Fatal("4th borrow reused a connection past MaxUsage")
+	}
+	_ = p.Put(conn)
+}
+
+func TestMaxIdleZero(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 2
+	cfg.MaxIdle = 0
+	var closed int32
+	cfg.Close = func(interface{}) error {
+		atomic.AddInt32(&closed, 1)
+		return nil
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool with MaxIdle=0: %v", err)
+	}
+	defer p.Shutdown()
+
+	a, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	b, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	//MaxCap 仍然限制并发
+	if _, err := p.TryGet(); err != ErrPoolExhausted {
+		t.Fatalf("TryGet at MaxCap: got %v, want ErrPoolExhausted", err)
+	}
+
+	//没有等待请求时 归还的连接直接关闭
+	_ = p.Put(a)
+	_ = p.Put(b)
+	if got := atomic.LoadInt32(&closed); got != 2 {
+		t.Fatalf("closed %d connections on Put, want 2", got)
+	}
+	stats := p.Stats()
+	if stats.IdleCount != 0 || stats.OpeningConn != 0 {
+		t.Fatalf("IdleCount/OpeningConn = %d/%d, want 0/0", stats.IdleCount, stats.OpeningConn)
+	}
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get after Put: %v", err)
+	}
+	if conn == a || conn == b {
+		t.Fatal("Get reused a connection with MaxIdle=0")
+	}
+	_ = p.Put(conn)
+}
+
+func TestIdleOverflow(t *testing.T) {
+	clk := newFakeClock()
+	cfg := newTestConfig()
+	cfg.MaxIdle = 2
+	cfg.IdleOverflow = true
+	cfg.MaintainInterval = 10 * time.Second
+	cfg.clock = clk
+	var created, closed int32
+	factory := cfg.Factory
+	cfg.Factory = func() (interface{}, error) { atomic.AddInt32(&created, 1); return factory() }
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	waitFor(t, func() bool { return clk.tickers() == 1 })
+
+	//多次突发借出 MaxCap 个连接 归还后全部保留在空闲队列中被下一次突发复用
+	for burst := 0; burst < 3; burst++ {
+		conns := make([]any, 0, cfg.MaxCap)
+		for i := int32(0); i < cfg.MaxCap; i++ {
+			conn, err := p.Get()
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			conns = append(conns, conn)
+		}
+		for _, conn := range conns {
+			if err := p.Put(conn); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+		}
+		if n := p.IdleLen(); n != int(cfg.MaxCap) {
+			t.Fatalf("IdleLen = %d after burst %d, want %d", n, burst, cfg.MaxCap)
+		}
+	}
+	if c, d := atomic.LoadInt32(&created), atomic.LoadInt32(&closed); c != cfg.MaxCap || d != 0 {
+		t.Fatalf("created/closed %d/%d connections, want %d/0", c, d, cfg.MaxCap)
+	}
+
+	//突发结束后超出 MaxIdle 的连接空闲超过 MaintainInterval 后被回收
+	clk.Advance(10 * time.Second)
+	waitFor(t, func() bool { return atomic.LoadInt32(&closed) == cfg.MaxCap-2 })
+	if n := p.IdleLen(); n != 2 {
+		t.Fatalf("IdleLen = %d after trimming, want 2", n)
+	}
+}
+
+func TestInvalidateReplacesForWaiter(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	var closed int32
+	cfg.Close = func(interface{}) error {
+		atomic.AddInt32(&closed, 1)
+		return nil
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	broken, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got := make(chan interface{}, 1)
+	go func() {
+		conn, err := p.Get()
+		if err != nil {
+			t.Errorf("waiting Get: %v", err)
+		}
+		got <- conn
+	}()
+	for p.Stats().WaitingRequests == 0 {
+		runtime.Gosched()
+	}
+
+	if err := p.Invalidate(broken); err != nil {
+		t.Fatalf("Invalidate: %v", err)
+	}
+	select {
+	case conn := <-got:
+		if conn == nil || conn == broken {
+			t.Fatalf("waiter got %v, want a replacement connection", conn)
+		}
+		_ = p.Put(conn)
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("waiter was not served after Invalidate")
+	}
+	if got := atomic.LoadInt32(&closed); got != 1 {
+		t.Fatalf("closed %d connections, want 1", got)
+	}
+	if got := p.Stats().OpeningConn; got != 1 {
+		t.Fatalf("OpeningConn = %d, want 1", got)
+	}
+	if err := p.Invalidate(broken); err != ErrUnknownConnection {
+		t.Fatalf("second Invalidate: got %v, want ErrUnknownConnection", err)
+	}
+}
+
+func TestFactoryContextCancel(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Factory = nil
+	cfg.FactoryContext = func(ctx context.Context) (interface{}, error) {
+		select {
+		case <-time.After(time.Second):
+			return &testConn{}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := p.GetContext(ctx); err != context.Canceled {
+		t.Fatalf("GetContext: got %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("GetContext returned after %v, want prompt return on cancel", elapsed)
+	}
+	if got := p.Stats().OpeningConn; got != 0 {
+		t.Fatalf("OpeningConn = %d, want 0", got)
+	}
+}
+
+func TestWaitersServedFIFO(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	cfg.WaitTimeout = 5 * time.Second
+	cfg.WaitQueue = 64
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	held, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	const waiters = 40
+	var (
+		mu     sync.Mutex
+		served []int
+		wg     sync.WaitGroup
+	)
+	cancels := make([]context.CancelFunc, 0, waiters)
+	for i := 0; i < waiters; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancels = append(cancels, cancel)
+		wg.Add(1)
+		go func(i int, ctx context.Context) {
+			defer wg.Done()
+			conn, err := p.GetContext(ctx)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			served = append(served, i)
+			mu.Unlock()
+			_ = p.Put(conn)
+		}(i, ctx)
+		//等待该请求进入队列 保证入队顺序与 i 一致
+		for p.Stats().WaitingRequests != int32(i+1) {
+			runtime.Gosched()
+		}
+	}
+
+	//放弃三分之二的请求 它们夹在存活的请求之间
+	live := 0
+	for i, cancel := range cancels {
+		if i%3 != 0 {
+			cancel()
+		} else {
+			live++
+		}
+	}
+	for p.Stats().WaitingRequests != int32(live) {
+		runtime.Gosched()
+	}
+	_ = p.Put(held)
+	wg.Wait()
+	for _, cancel := range cancels {
+		cancel()
+	}
+
+	if len(served) != live {
+		t.Fatalf("served %d live waiters, want %d", len(served), live)
+	}
+	for j, i := range served {
+		if i != j*3 {
+			t.Fatalf("live waiters served in order %v, want FIFO", served)
+		}
+	}
+}
+
+func TestWaitQueueFull(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	cfg.WaitQueue = 1
+	cfg.WaitTimeout = 50 * time.Millisecond
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	held, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer p.Put(held)
+
+	//第一个请求进入等待队列 等待超时
+	timedOut := make(chan error, 1)
+	go func() {
+		_, err := p.Get()
+		timedOut <- err
+	}()
+	for p.Stats().WaitingRequests != 1 {
+		runtime.Gosched()
+	}
+
+	//等待队列已满 第二个请求立即失败 不会阻塞
+	start := time.Now()
+	if _, err := p.Get(); err != ErrWaitQueueFull {
+		t.Fatalf("Get with full wait queue: got %v, want ErrWaitQueueFull", err)
+	}
+	if elapsed := time.Since(start); elapsed > 25*time.Millisecond {
+		t.Fatalf("Get with full wait queue blocked for %v", elapsed)
+	}
+	if err := <-timedOut; !errors.Is(err, GetConnectionTimeout) {
+		t.Fatalf("queued Get: got %v, want GetConnectionTimeout", err)
+	}
+	if got := p.Stats().TotalTimeouts; got != 1 {
+		t.Fatalf("TotalTimeouts = %d, want 1", got)
+	}
+}
+
+func TestHealthCheckPrunesDeadConn(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MinIdle = 2
+	cfg.MaintainInterval = time.Hour
+	cfg.HealthCheckInterval = 20 * time.Millisecond
+	var dead atomic.Value
+	cfg.HealthCheck = func(conn interface{}) error {
+		if conn == dead.Load() {
+			return errors.New("connection reset")
+		}
+		return nil
+	}
+	var closed int32
+	cfg.Close = func(interface{}) error {
+		atomic.AddInt32(&closed, 1)
+		return nil
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	a, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	b, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	_ = p.Put(a)
+	_ = p.Put(b)
+
+	dead.Store(a)
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for atomic.LoadInt32(&closed) == 0 || p.IdleLen() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("dead connection not replaced: closed %d IdleLen %d", atomic.LoadInt32(&closed), p.IdleLen())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	for i := 0; i < 2; i++ {
+		conn, err := p.TryGet()
+		if err != nil {
+			t.Fatalf("TryGet: %v", err)
+		}
+		if conn == a {
+			t.Fatal("dead connection is still in the idle queue")
+		}
+		defer p.Put(conn)
+	}
+}
+
+func TestHealthCheckKeepsOthersIdle(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.InitialCap = 3
+	cfg.MaintainInterval = time.Hour
+	cfg.HealthCheckInterval = time.Hour
+	entered, release := make(chan struct{}), make(chan struct{})
+	var calls int32
+	cfg.HealthCheck = func(interface{}) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(entered)
+			<-release
+		}
+		return nil
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		p.(*connectionPool).checkIdle()
+		close(done)
+	}()
+	<-entered
+	//正在检测的连接之外的空闲连接仍可借出
+	if got := p.IdleLen(); got != 2 {
+		t.Fatalf("IdleLen during health check = %d, want 2", got)
+	}
+	conn, err := p.TryGet()
+	if err != nil {
+		t.Fatalf("TryGet during health check: %v", err)
+	}
+	_ = p.Put(conn)
+	close(release)
+	<-done
+	if got := p.IdleLen(); got != 3 {
+		t.Fatalf("IdleLen after health check = %d, want 3", got)
+	}
+}
+
+func TestGetTrace(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	var infos []GetInfo
+	ctx := WithGetTrace(context.Background(), &GetTrace{
+		GotConn: func(info GetInfo) { infos = append(infos, info) },
+	})
+	conn, err := p.GetContext(ctx)
+	if err != nil {
+		t.Fatalf("GetContext: %v", err)
+	}
+	_ = p.Put(conn)
+	conn, err = p.GetContext(ctx)
+	if err != nil {
+		t.Fatalf("GetContext: %v", err)
+	}
+	time.AfterFunc(20*time.Millisecond, func() { _ = p.Put(conn) })
+	if conn, err = p.GetContext(ctx); err != nil {
+		t.Fatalf("GetContext: %v", err)
+	}
+	defer p.Put(conn)
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, _ = p.GetContext(short)
+
+	want := []ConnSource{SourceCreated, SourceIdle, SourceWaited, SourceNone}
+	if len(infos) != len(want) {
+		t.Fatalf("got %d trace callbacks, want %d", len(infos), len(want))
+	}
+	for i, info := range infos {
+		if info.Source != want[i] {
+			t.Fatalf("trace %d source = %v, want %v", i, info.Source, want[i])
+		}
+	}
+	if infos[2].Waited < 10*time.Millisecond {
+		t.Fatalf("waited Get recorded Waited %v, want about 20ms", infos[2].Waited)
+	}
+	if infos[3].Err != context.DeadlineExceeded {
+		t.Fatalf("failed Get recorded Err %v, want context.DeadlineExceeded", infos[3].Err)
+	}
+}
+
+func TestStatsSnapshotAndReset(t *testing.T) {
+	const n = 20
+	cfg := newTestConfig()
+	cfg.WaitQueue = 4 * n
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	run := func(gets int) {
+		var wg sync.WaitGroup
+		for i := 0; i < gets; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				conn, err := p.Get()
+				if err != nil {
+					t.Errorf("Get: %v", err)
+					return
+				}
+				time.Sleep(time.Millisecond)
+				_ = p.Put(conn)
+			}()
+		}
+		wg.Wait()
+	}
+
+	run(n)
+	held, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	first := p.StatsSnapshotAndReset()
+	if first.TotalGets != n+1 || first.ActiveCount != 1 {
+		t.Fatalf("first snapshot TotalGets/ActiveCount = %d/%d, want %d/1", first.TotalGets, first.ActiveCount, n+1)
+	}
+
+	//重置期间并发的 Get 计数不会丢失
+	var wg sync.WaitGroup
+	var reset int64
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			reset += p.StatsSnapshotAndReset().TotalGets
+		}
+	}()
+	run(2 * n)
+	wg.Wait()
+	second := p.StatsSnapshotAndReset()
+	if got := reset + second.TotalGets; got != 2*n {
+		t.Fatalf("gets after reset = %d, want %d", got, 2*n)
+	}
+	//当前连接数不被重置
+	if second.ActiveCount != 1 {
+		t.Fatalf("ActiveCount = %d after reset, want 1", second.ActiveCount)
+	}
+	_ = p.Put(held)
+}
+
+func TestWaitTimeoutZeroWaitsForever(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	cfg.WaitTimeout = 0
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	held, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got := make(chan error, 1)
+	go func() {
+		conn, err := p.Get()
+		if err == nil {
+			_ = p.Put(conn)
+		}
+		got <- err
+	}()
+	select {
+	case err := <-got:
+		t.Fatalf("Get with WaitTimeout=0 returned early: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	_ = p.Put(held)
+	select {
+	case err := <-got:
+		if err != nil {
+			t.Fatalf("blocked Get: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked Get was not woken by Put")
+	}
+}
+
+func TestOpeningConnAccounting(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 6
+	cfg.MaxIdle = 3
+	cfg.WaitQueue = 100
+	cfg.WaitTimeout = 5 * time.Millisecond
+	cfg.MaxLifetime = 20 * time.Millisecond
+	cfg.MaxUsage = 5
+	var calls int32
+	factory := cfg.Factory
+	cfg.Factory = func() (interface{}, error) {
+		if atomic.AddInt32(&calls, 1)%7 == 0 {
+			return nil, errors.New("dial failed")
+		}
+		return factory()
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				var conn interface{}
+				var err error
+				switch (i + j) % 3 {
+				case 0:
+					conn, err = p.Get()
+				case 1:
+					conn, err = p.TryGet()
+				default:
+					conn, err = p.GetWithTimeout(time.Millisecond)
+				}
+				if err != nil {
+					continue
+				}
+				if j%5 == 0 {
+					_ = p.Invalidate(conn)
+				} else {
+					_ = p.Put(conn)
+				}
+				if j%50 == 0 {
+					_ = p.SetMaxIdle(int32(1 + j%3))
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	//等待后台补充连接的协程结束
+	deadline := time.Now().Add(time.Second)
+	for {
+		stats := p.Stats()
+		if stats.OpeningConn == stats.IdleCount+stats.ActiveCount && stats.OpeningConn >= 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("OpeningConn = %d, want idle %d + borrowed %d", stats.OpeningConn, stats.IdleCount, stats.ActiveCount)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGetReconnectsExpiredIdleConn(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	cfg.IdleTimeout = 20 * time.Millisecond
+	cfg.MaintainInterval = time.Hour
+	var closed int32
+	cfg.Close = func(interface{}) error {
+		atomic.AddInt32(&closed, 1)
+		return nil
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	stale, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	_ = p.Put(stale)
+	time.Sleep(30 * time.Millisecond)
+
+	start := time.Now()
+	fresh, err := p.TryGet()
+	if err != nil {
+		t.Fatalf("TryGet with an expired idle connection: %v", err)
+	}
+	defer p.Put(fresh)
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Fatalf("Get blocked for %v", elapsed)
+	}
+	if fresh == stale {
+		t.Fatal("expired idle connection was handed out")
+	}
+	stats := p.Stats()
+	if atomic.LoadInt32(&closed) != 1 || stats.OpeningConn != 1 {
+		t.Fatalf("closed/OpeningConn = %d/%d, want 1/1", atomic.LoadInt32(&closed), stats.OpeningConn)
+	}
+}
+
+func TestPoolString(t *testing.T) {
+	p, err := NewPool(newTestConfig())
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = fmt.Sprint(p)
+		}()
+	}
+	wg.Wait()
+	got := fmt.Sprintf("%v", p)
+	for _, want := range []string{"idle: 0", "active: 1", "opening: 1", "maxCap: 10", "waiting: 0", "closed: false"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("String() = %q, missing %q", got, want)
+		}
+	}
+	_ = p.Put(conn)
+	_ = p.Shutdown()
+	if got := fmt.Sprint(p); !strings.Contains(got, "closed: true") {
+		t.Fatalf("String() after Shutdown = %q, want closed: true", got)
+	}
+}
+
+func BenchmarkGetWaitPath(b *testing.B) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	cfg.WaitQueue = 1024
+	cfg.WaitTimeout = 0
+	p, err := NewPool(cfg)
+	if err != nil {
+		b.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	b.ReportAllocs()
+	//只有一个连接 几乎所有请求都会进入等待队列
+	b.SetParallelism(16)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			conn, err := p.Get()
+			if err != nil {
+				b.Errorf("Get: %v", err)
+				return
+			}
+			//持有连接时让出处理器 让其他请求进入等待
+			runtime.Gosched()
+			_ = p.Put(conn)
+		}
+	})
+}
+
+func TestCloseConnAndShutdownAreDistinct(t *testing.T) {
+	cfg := newTestConfig()
+	var closed int32
+	cfg.Close = func(interface{}) error {
+		atomic.AddInt32(&closed, 1)
+		return nil
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	a, _ := p.Get()
+	b, _ := p.Get()
+
+	//CloseConn 只关闭一个连接 连接池仍然可用
+	if err := p.CloseConn(a); err != nil {
+		t.Fatalf("CloseConn: %v", err)
+	}
+	if p.IsClosed() || atomic.LoadInt32(&closed) != 1 {
+		t.Fatalf("after CloseConn: IsClosed %v closed %d, want false and 1", p.IsClosed(), atomic.LoadInt32(&closed))
+	}
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get after CloseConn: %v", err)
+	}
+	_ = p.Put(conn)
+
+	//已弃用的 Close(conn) 与 CloseConn 行为相同
+	if err := p.Close(b); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if p.IsClosed() || atomic.LoadInt32(&closed) != 2 {
+		t.Fatalf("after Close(conn): IsClosed %v closed %d, want false and 2", p.IsClosed(), atomic.LoadInt32(&closed))
+	}
+
+	//Shutdown 关闭整个连接池
+	if err := p.Shutdown(); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if !p.IsClosed() || atomic.LoadInt32(&closed) != 3 {
+		t.Fatalf("after Shutdown: IsClosed %v closed %d, want true and 3", p.IsClosed(), atomic.LoadInt32(&closed))
+	}
+	if _, err := p.Get(); err != PoolClosed {
+		t.Fatalf("Get after Shutdown: got %v, want PoolClosed", err)
+	}
+}
+
+//saturatedQueuePool 返回一个连接已全部借出 等待队列已满的连接池 以及被借出的连接
+//占用等待队列的请求使用较长的超时时间 结束后将结果写入 occupied
+func saturatedQueuePool(t *testing.T, policy QueueFullPolicy) (Pool, interface{}, chan error) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	cfg.WaitQueue = 1
+	cfg.WaitTimeout = 30 * time.Millisecond
+	cfg.OnQueueFull = policy
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	held, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	occupied := make(chan error, 1)
+	go func() {
+		conn, err := p.GetWithTimeout(5 * time.Second)
+		if err == nil {
+			_ = p.Put(conn)
+		}
+		occupied <- err
+	}()
+	for p.Stats().WaitingRequests != 1 {
+		runtime.Gosched()
+	}
+	return p, held, occupied
+}
+
+func TestQueueFullReject(t *testing.T) {
+	p, held, occupied := saturatedQueuePool(t, QueueFullReject)
+	defer p.Shutdown()
+	start := time.Now()
+	if _, err := p.Get(); err != ErrWaitQueueFull {
+		t.Fatalf("Get: got %v, want ErrWaitQueueFull", err)
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Fatalf("Reject blocked for %v", elapsed)
+	}
+	_ = p.Put(held)
+	if err := <-occupied; err != nil {
+		t.Fatalf("queued Get: %v", err)
+	}
+}
+
+func TestQueueFullTimeout(t *testing.T) {
+	p, held, occupied := saturatedQueuePool(t, QueueFullTimeout)
+	defer p.Shutdown()
+	start := time.Now()
+	if _, err := p.Get(); err != ErrWaitQueueFull {
+		t.Fatalf("Get: got %v, want ErrWaitQueueFull", err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Fatalf("Timeout returned after %v, want at least WaitTimeout", elapsed)
+	}
+	_ = p.Put(held)
+	if err := <-occupied; err != nil {
+		t.Fatalf("queued Get: %v", err)
+	}
+}
+
+func TestQueueFullBlock(t *testing.T) {
+	p, held, occupied := saturatedQueuePool(t, QueueFullBlock)
+	defer p.Shutdown()
+	got := make(chan error, 1)
+	go func() {
+		conn, err := p.Get()
+		if err == nil {
+			_ = p.Put(conn)
+		}
+		got <- err
+	}()
+	//超过 WaitTimeout 仍然在等待空位
+	select {
+	case err := <-got:
+		t.Fatalf("blocked Get returned early: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	//归还连接后 占用队列的请求先获取连接 归还后等待空位的请求获取连接
+	_ = p.Put(held)
+	if err := <-occupied; err != nil {
+		t.Fatalf("queued Get: %v", err)
+	}
+	select {
+	case err := <-got:
+		if err != nil {
+			t.Fatalf("blocked Get: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked Get was not served after a slot freed")
+	}
+}
+
+func TestWaitLatencyHistogram(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	cfg.WaitQueue = 1
+	cfg.WaitTimeout = 5 * time.Second
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	//命中空闲连接的 Get 不计入分布
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	//依次让一个请求等待约 20ms 和约 200ms
+	for _, hold := range []time.Duration{20 * time.Millisecond, 200 * time.Millisecond} {
+		got := make(chan interface{}, 1)
+		go func() {
+			c, err := p.Get()
+			if err != nil {
+				t.Errorf("waiting Get: %v", err)
+			}
+			got <- c
+		}()
+		for p.Stats().WaitingRequests != 1 {
+			runtime.Gosched()
+		}
+		time.Sleep(hold)
+		_ = p.Put(conn)
+		conn = <-got
+	}
+	_ = p.Put(conn)
+
+	h := p.Stats().WaitLatency
+	want := [len(WaitBuckets) + 1]int64{0, 0, 1, 1, 0}
+	if h.Buckets != want {
+		t.Fatalf("Buckets = %v, want %v", h.Buckets, want)
+	}
+	if h.Count != 2 || h.Sum < 220*time.Millisecond {
+		t.Fatalf("Count = %d Sum = %v, want 2 and at least 220ms", h.Count, h.Sum)
+	}
+	if reset := p.StatsSnapshotAndReset().WaitLatency; reset.Buckets != want {
+		t.Fatalf("snapshot Buckets = %v, want %v", reset.Buckets, want)
+	}
+	if h := p.Stats().WaitLatency; h.Count != 0 || h.Buckets != ([len(WaitBuckets) + 1]int64{}) {
+		t.Fatalf("after reset: %+v", h)
+	}
+}
+
+func TestUnboundedMaxCap(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 0
+	cfg.MaxIdle = 2
+	//不会进入等待队列 即使不允许等待也不会失败
+	cfg.WaitQueue = 0
+	var closed int32
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	const n = 50
+	conns := make(chan interface{}, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := p.Get()
+			if err != nil {
+				t.Errorf("Get: %v", err)
+				return
+			}
+			conns <- conn
+		}()
+	}
+	wg.Wait()
+	close(conns)
+	if s := p.Stats(); s.OpeningConn != n || s.ActiveCount != n || s.TotalWaits != 0 {
+		t.Fatalf("OpeningConn/ActiveCount/TotalWaits = %d/%d/%d, want %d/%d/0", s.OpeningConn, s.ActiveCount, s.TotalWaits, n, n)
+	}
+	//归还后只保留 MaxIdle 个空闲连接 多余的被关闭
+	for conn := range conns {
+		_ = p.Put(conn)
+	}
+	if s, c := p.Stats(), atomic.LoadInt32(&closed); s.IdleCount != 2 || s.OpeningConn != 2 || c != n-2 {
+		t.Fatalf("IdleCount/OpeningConn/closed = %d/%d/%d, want 2/2/%d", s.IdleCount, s.OpeningConn, c, n-2)
+	}
+}
+
+func TestIdleTimeoutJitter(t *testing.T) {
+	const n = 20
+	cfg := newTestConfig()
+	cfg.MaxCap = n
+	cfg.MaxIdle = n
+	cfg.IdleTimeout = 100 * time.Millisecond
+	cfg.IdleTimeoutJitter = 80 * time.Millisecond
+	cfg.MaintainInterval = 2 * time.Millisecond
+	var (
+		mu       sync.Mutex
+		closedAt []time.Time
+	)
+	cfg.Close = func(interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		closedAt = append(closedAt, time.Now())
+		return nil
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	//同时借出并同时归还 所有连接在同一时刻变为空闲
+	conns := make([]interface{}, n)
+	for i := range conns {
+		if conns[i], err = p.Get(); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+	}
+	for _, conn := range conns {
+		_ = p.Put(conn)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for p.IdleLen() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(closedAt) != n {
+		t.Fatalf("reaped %d connections, want %d", len(closedAt), n)
+	}
+	first, last := closedAt[0], closedAt[len(closedAt)-1]
+	//失效时间分布在 [20ms, 180ms] 之间 不会全部在同一次扫描中被关闭
+	if spread := last.Sub(first); spread < 40*time.Millisecond {
+		t.Fatalf("connections expired within %v of each other, want them spread out", spread)
+	}
+}
+
+func TestPutDoesNotBlockOnAbandonedWaiter(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	//等待中的请求放弃与归还连接同时发生 请求可能在收到连接之后才处理取消
+	for i := 0; i < 200; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		got := make(chan interface{}, 1)
+		go func() {
+			c, _ := p.GetContext(ctx)
+			got <- c
+		}()
+		for p.Stats().WaitingRequests != 1 {
+			runtime.Gosched()
+		}
+		put := make(chan error, 1)
+		cancel()
+		go func() { put <- p.Put(conn) }()
+		select {
+		case err := <-put:
+			if err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Put blocked on an abandoned waiter")
+		}
+		//连接要么交给了请求 要么回到空闲队列
+		if c := <-got; c != nil {
+			conn = c
+		} else if conn, err = p.TryGet(); err != nil {
+			t.Fatalf("connection lost after abandoned handoff: %v", err)
+		}
+		if p.Len() != 1 {
+			t.Fatalf("Len = %d, want 1", p.Len())
+		}
+	}
+	_ = p.Put(conn)
+}
+
+func TestRefreshConn(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	var fail int32
+	factory := cfg.Factory
+	cfg.Factory = func() (interface{}, error) {
+		if atomic.LoadInt32(&fail) == 1 {
+			return nil, errors.New("dial failed")
+		}
+		return factory()
+	}
+	var closed int32
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	old, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	fresh, err := p.RefreshConn(old)
+	if err != nil {
+		t.Fatalf("RefreshConn: %v", err)
+	}
+	if c := atomic.LoadInt32(&closed); fresh == old || c != 1 {
+		t.Fatalf("RefreshConn returned the old connection or did not close it (closed %d)", c)
+	}
+	//沿用连接数 不会超过 MaxCap
+	if s := p.Stats(); s.OpeningConn != 1 || s.ActiveCount != 1 {
+		t.Fatalf("OpeningConn/ActiveCount = %d/%d, want 1/1", s.OpeningConn, s.ActiveCount)
+	}
+	if err := p.Put(old); err != ErrUnknownConnection {
+		t.Fatalf("Put(old): got %v, want ErrUnknownConnection", err)
+	}
+
+	//创建失败时释放连接数 其他请求可以重新创建连接
+	atomic.StoreInt32(&fail, 1)
+	if _, err := p.RefreshConn(fresh); err == nil {
+		t.Fatal("RefreshConn succeeded with a failing factory")
+	}
+	if s := p.Stats(); s.OpeningConn != 0 || s.ActiveCount != 0 {
+		t.Fatalf("OpeningConn/ActiveCount = %d/%d after failed refresh, want 0/0", s.OpeningConn, s.ActiveCount)
+	}
+	atomic.StoreInt32(&fail, 0)
+	conn, err := p.TryGet()
+	if err != nil {
+		t.Fatalf("TryGet after failed refresh: %v", err)
+	}
+	_ = p.Put(conn)
+}
+
+func TestValidateOnPut(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.ValidateOnPut = true
+	var broken int32
+	var validated int32
+	cfg.Validate = func(conn interface{}) error {
+		atomic.AddInt32(&validated, 1)
+		if conn.(*testConn).id == int(atomic.LoadInt32(&broken)) {
+			return errors.New("broken")
+		}
+		return nil
+	}
+	var closed int32
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	good, _ := p.Get()
+	bad, _ := p.Get()
+	atomic.StoreInt32(&broken, int32(bad.(*testConn).id))
+	if err := p.Put(bad); err != nil {
+		t.Fatalf("Put(bad): %v", err)
+	}
+	if err := p.Put(good); err != nil {
+		t.Fatalf("Put(good): %v", err)
+	}
+	if atomic.LoadInt32(&closed) != 1 || p.IdleLen() != 1 || p.Stats().OpeningConn != 1 {
+		t.Fatalf("closed/IdleLen/OpeningConn = %d/%d/%d, want 1/1/1", atomic.LoadInt32(&closed), p.IdleLen(), p.Stats().OpeningConn)
+	}
+	if atomic.LoadInt32(&validated) != 2 {
+		t.Fatalf("Validate called %d times, want 2", atomic.LoadInt32(&validated))
+	}
+	if conn, _ := p.Get(); conn != good {
+		t.Fatalf("Get returned %v, want the connection that passed validation", conn)
+	}
+}
+
+func TestNewPoolWrapsFactoryError(t *testing.T) {
+	errAuth := errors.New("auth failed")
+	cfg := newTestConfig()
+	cfg.InitialCap = 3
+	var created, closed int32
+	cfg.Factory = func() (interface{}, error) {
+		if atomic.AddInt32(&created, 1) == 2 {
+			return nil, errAuth
+		}
+		return &testConn{}, nil
+	}
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	_, err := NewPool(cfg)
+	if !errors.Is(err, errAuth) || !errors.Is(err, InitPoolErr) {
+		t.Fatalf("NewPool with failing factory: got %v, want it to wrap InitPoolErr and the factory error", err)
+	}
+	if n := atomic.LoadInt32(&closed); n != 1 {
+		t.Fatalf("closed %d connections after failed warmup, want 1", n)
+	}
+}
+
+func TestNewPoolContextCancelWarmup(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.InitialCap = 5
+	var created int32
+	cfg.Factory = nil
+	cfg.FactoryContext = func(ctx context.Context) (interface{}, error) {
+		//前两个连接立即创建成功 之后的连接一直阻塞到 ctx 结束
+		if n := atomic.AddInt32(&created, 1); n <= 2 {
+			return &testConn{id: int(n)}, nil
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	var closed int32
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	p, err := NewPoolContext(ctx, cfg)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("NewPoolContext: got %v, want context.DeadlineExceeded", err)
+	}
+	if p != nil {
+		t.Fatal("NewPoolContext returned a pool after cancellation")
+	}
+	if got := atomic.LoadInt32(&closed); got != 2 {
+		t.Fatalf("closed %d partial connections, want 2", got)
+	}
+}
+
+func TestPing(t *testing.T) {
+	cfg := newTestConfig()
+	var checked int32
+	cfg.HealthCheck = func(interface{}) error { atomic.AddInt32(&checked, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	if err := p.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	//连接检测后归还 不会被占用
+	if s := p.Stats(); s.ActiveCount != 0 || s.IdleCount != 1 || atomic.LoadInt32(&checked) != 1 {
+		t.Fatalf("ActiveCount/IdleCount/checked = %d/%d/%d, want 0/1/1", s.ActiveCount, s.IdleCount, atomic.LoadInt32(&checked))
+	}
+
+	dialErr := errors.New("backend unreachable")
+	cfg = newTestConfig()
+	cfg.Factory = func() (interface{}, error) { return nil, dialErr }
+	broken, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer broken.Shutdown()
+	if err := broken.Ping(context.Background()); err != dialErr {
+		t.Fatalf("Ping: got %v, want the factory error", err)
+	}
+}
+
+func TestGetWithPriority(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	held, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	//依次加入等待队列 高优先级的请求最后加入
+	waiters := []struct {
+		name     string
+		priority int
+	}{{"bulk-1", 0}, {"bulk-2", 0}, {"health", 10}, {"control", 5}}
+	served := make(chan string, len(waiters))
+	for i, w := range waiters {
+		go func(name string, priority int) {
+			conn, err := p.GetWithPriority(context.Background(), priority)
+			if err != nil {
+				t.Errorf("GetWithPriority(%s): %v", name, err)
+				served <- name
+				return
+			}
+			served <- name
+			_ = p.Put(conn)
+		}(w.name, w.priority)
+		for p.Stats().WaitingRequests != int32(i+1) {
+			runtime.Gosched()
+		}
+	}
+	_ = p.Put(held)
+
+	want := []string{"health", "control", "bulk-1", "bulk-2"}
+	for i, name := range want {
+		if got := <-served; got != name {
+			t.Fatalf("served #%d = %s, want %s", i, got, name)
+		}
+	}
+}
+
+func TestShutdownClosesConcurrently(t *testing.T) {
+	const n = 8
+	cfg := newTestConfig()
+	cfg.InitialCap = n
+	cfg.MaxIdle = n
+	cfg.ShutdownConcurrency = n
+	cfg.Close = func(interface{}) error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	start := time.Now()
+	if err := p.Shutdown(); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	//串行关闭需要 400ms
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Fatalf("Shutdown took %v, want roughly one close duration", elapsed)
+	}
+	if s := p.Stats(); s.OpeningConn != 0 || s.IdleCount != 0 {
+		t.Fatalf("OpeningConn/IdleCount = %d/%d after shutdown", s.OpeningConn, s.IdleCount)
+	}
+}
+
+func TestDrainContextHungClose(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.InitialCap = 2
+	closeErr := errors.New("close failed")
+	release := make(chan struct{})
+	var calls int32
+	cfg.Close = func(interface{}) error {
+		//第一个连接关闭失败 第二个连接的关闭一直阻塞
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return closeErr
+		}
+		<-release
+		return nil
+	}
+	cfg.ShutdownConcurrency = 1
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = p.DrainContext(ctx)
+	if !errors.Is(err, ErrDrainTimeout) || !errors.Is(err, closeErr) {
+		t.Fatalf("DrainContext: got %v, want ErrDrainTimeout joined with the close error", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("DrainContext blocked for %v on a hung close", elapsed)
+	}
+}
+
+func TestGetUncounted(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 2
+	cfg.MaxIdle = 2
+	var closed int32
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	a, _ := p.Get()
+	b, _ := p.Get()
+	if _, err := p.TryGet(); err != ErrPoolExhausted {
+		t.Fatalf("TryGet: got %v, want ErrPoolExhausted", err)
+	}
+	//连接数已满时仍然可以获取不计入 MaxCap 的连接
+	monitor, err := p.GetUncounted()
+	if err != nil {
+		t.Fatalf("GetUncounted: %v", err)
+	}
+	if s := p.Stats(); s.UncountedConn != 1 || s.OpeningConn != 2 || s.ActiveCount != 2 {
+		t.Fatalf("UncountedConn/OpeningConn/ActiveCount = %d/%d/%d, want 1/2/2", s.UncountedConn, s.OpeningConn, s.ActiveCount)
+	}
+	//归还后直接关闭 不会进入空闲队列
+	if err := p.Put(monitor); err != nil {
+		t.Fatalf("Put(uncounted): %v", err)
+	}
+	if s := p.Stats(); s.UncountedConn != 0 || s.IdleCount != 0 || atomic.LoadInt32(&closed) != 1 {
+		t.Fatalf("UncountedConn/IdleCount/closed = %d/%d/%d, want 0/0/1", s.UncountedConn, s.IdleCount, atomic.LoadInt32(&closed))
+	}
+	_ = p.Put(a)
+	_ = p.Put(b)
+	if s := p.Stats(); s.OpeningConn != 2 || s.IdleCount != 2 {
+		t.Fatalf("OpeningConn/IdleCount = %d/%d, want 2/2", s.OpeningConn, s.IdleCount)
+	}
+}
+
+func TestBorrowTrackingDoesNotLeak(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.LeakThreshold = time.Hour
+	cfg.MaintainInterval = time.Millisecond
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	c := p.(*connectionPool)
+
+	rounds := 1000000
+	if testing.Short() {
+		rounds = 10000
+	}
+	workers := runtime.GOMAXPROCS(0)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds/workers; i++ {
+				conn, err := p.Get()
+				if err != nil {
+					t.Errorf("Get: %v", err)
+					return
+				}
+				//交替使用 Put 与 Invalidate 两种归还方式
+				if i%100 == 0 {
+					_ = p.Invalidate(conn)
+				} else {
+					_ = p.Put(conn)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n := c.borrowedLen(); n != 0 || p.ActiveLen() != 0 {
+		t.Fatalf("borrowed records/ActiveLen = %d/%d after churn, want 0/0", n, p.ActiveLen())
+	}
+}
+
+func TestGetMany(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 2
+	cfg.MaxIdle = 2
+	cfg.WaitTimeout = 30 * time.Millisecond
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	conns, err := p.GetMany(context.Background(), 2)
+	if err != nil || len(conns) != 2 {
+		t.Fatalf("GetMany(2): %v, %d connections", err, len(conns))
+	}
+	for _, conn := range conns {
+		_ = p.Put(conn)
+	}
+
+	//n 大于 MaxCap 时超时失败 已经获取的连接全部归还
+	conns, err = p.GetMany(context.Background(), 3)
+	if !errors.Is(err, GetConnectionTimeout) || conns != nil {
+		t.Fatalf("GetMany(3): got %v, %v, want GetConnectionTimeout and no connections", conns, err)
+	}
+	if p.ActiveLen() != 0 || p.IdleLen() != 2 {
+		t.Fatalf("ActiveLen/IdleLen = %d/%d, want 0/2", p.ActiveLen(), p.IdleLen())
+	}
+}
+
+func TestCloseErrorSemantics(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 2
+	cfg.MaxIdle = 0
+	closeErr := errors.New("close failed")
+	cfg.Close = func(interface{}) error { return closeErr }
+	var hooked int32
+	cfg.OnClose = func(_ interface{}, err error) {
+		if err == closeErr {
+			atomic.AddInt32(&hooked, 1)
+		}
+	}
+	logger := &captureLogger{}
+	cfg.Logger = logger
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	events := p.Events()
+
+	//MaxIdle 为0 归还时关闭连接 Invalidate 同样关闭连接
+	a, _ := p.Get()
+	b, _ := p.Get()
+	if err := p.Put(a); err != closeErr {
+		t.Fatalf("Put: got %v, want the close error", err)
+	}
+	if err := p.Invalidate(b); err != closeErr {
+		t.Fatalf("Invalidate: got %v, want the close error", err)
+	}
+	//连接数已经释放 可以重新创建连接
+	if s := p.Stats(); s.OpeningConn != 0 || s.ActiveCount != 0 {
+		t.Fatalf("OpeningConn/ActiveCount = %d/%d, want 0/0", s.OpeningConn, s.ActiveCount)
+	}
+	if got := atomic.LoadInt32(&hooked); got != 2 {
+		t.Fatalf("OnClose saw the error %d times, want 2", got)
+	}
+	if _, warns, _ := logger.counts(); warns != 2 {
+		t.Fatalf("logged %d close warnings, want 2", warns)
+	}
+	_ = p.Shutdown()
+	closeEvents := 0
+	for e := range events {
+		if e.Type == EventClose {
+			if e.Err != closeErr {
+				t.Fatalf("EventClose.Err = %v, want the close error", e.Err)
+			}
+			closeEvents++
+		}
+	}
+	if closeEvents != 2 {
+		t.Fatalf("got %d EventClose, want 2", closeEvents)
+	}
+}
+
+func TestIdleEvictsStalest(t *testing.T) {
+	clk := newFakeClock()
+	cfg := newTestConfig()
+	cfg.MaxIdle = 5
+	cfg.clock = clk
+	var mu sync.Mutex
+	var closed []int
+	cfg.Close = func(conn interface{}) error {
+		mu.Lock()
+		closed = append(closed, conn.(*testConn).id)
+		mu.Unlock()
+		return nil
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	conns := make([]any, 6)
+	for i := range conns {
+		if conns[i], err = p.Get(); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+	}
+	//按 3 1 5 2 4 的顺序归还 每次间隔 1s 归还越早越旧
+	for _, i := range []int{2, 0, 4, 1, 3} {
+		_ = p.Put(conns[i])
+		clk.Advance(time.Second)
+	}
+	snapshot := func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int(nil), closed...)
+	}
+
+	//空闲队列已满时归还连接 挤出最旧的 3 号连接
+	_ = p.Put(conns[5])
+	if got := snapshot(); len(got) != 1 || got[0] != 3 {
+		t.Fatalf("closed %v when idle queue is full, want [3]", got)
+	}
+
+	//缩小 MaxIdle 时按最近活跃时间关闭最旧的连接
+	if err := p.SetMaxIdle(2); err != nil {
+		t.Fatalf("SetMaxIdle: %v", err)
+	}
+	if got, want := snapshot(), []int{3, 1, 5, 2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("closed %v, want %v", got, want)
+	}
+	if p.IdleLen() != 2 {
+		t.Fatalf("IdleLen = %d, want 2", p.IdleLen())
+	}
+}
+
+func TestIdleEvictionSkipsPinned(t *testing.T) {
+	clk := newFakeClock()
+	cfg := newTestConfig()
+	cfg.InitialCap = 1
+	cfg.MaxIdle = 2
+	cfg.PinInitial = true
+	cfg.MaintainInterval = time.Hour
+	cfg.clock = clk
+	closed := make(chan any, 1)
+	cfg.Close = func(conn interface{}) error { closed <- conn; return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	pinned, _ := p.Get()
+	a, _ := p.Get()
+	b, _ := p.Get()
+	for _, conn := range []any{pinned, a, b} {
+		clk.Advance(time.Second)
+		_ = p.Put(conn)
+	}
+	//空闲队列已满 队头的固定连接被跳过 挤出最早归还的非固定连接
+	select {
+	case conn := <-closed:
+		if conn != a {
+			t.Fatalf("evicted %v, want %v", conn, a)
+		}
+	default:
+		t.Fatal("no connection was evicted from the full idle queue")
+	}
+	for _, want := range []any{pinned, b} {
+		if conn, _ := p.Get(); conn != want {
+			t.Fatalf("Get = %v, want %v in FIFO order", conn, want)
+		}
+	}
+}
+
+func TestGetWithInfo(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	conn, info, err := p.GetWithInfo(context.Background())
+	if err != nil {
+		t.Fatalf("GetWithInfo: %v", err)
+	}
+	if !info.Created || info.FromIdle {
+		t.Fatalf("first GetWithInfo info = %+v, want Created", info)
+	}
+	_ = p.Put(conn)
+
+	conn, info, err = p.GetWithInfo(context.Background())
+	if err != nil {
+		t.Fatalf("GetWithInfo: %v", err)
+	}
+	if !info.FromIdle || info.Created {
+		t.Fatalf("second GetWithInfo info = %+v, want FromIdle", info)
+	}
+
+	//连接数已满时等待其他请求归还
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = p.Put(conn)
+	}()
+	_, info, err = p.GetWithInfo(context.Background())
+	if err != nil {
+		t.Fatalf("GetWithInfo: %v", err)
+	}
+	if info.FromIdle || info.Created || info.Source != SourceWaited || info.Waited <= 0 {
+		t.Fatalf("waiting GetWithInfo info = %+v, want a waited connection", info)
+	}
+}
+
+func TestIdleFloorAfterEviction(t *testing.T) {
+	//pollFloor 等待 factory 至少被调用 created 次且空闲连接数与存活连接数都稳定在 floor
+	pollFloor := func(p Pool, factoryCalls *int32, created int32, floor int32) {
+		t.Helper()
+		ok := func() bool {
+			s := p.Stats()
+			return atomic.LoadInt32(factoryCalls) >= created && s.IdleCount == floor && s.OpeningConn == floor
+		}
+		deadline := time.Now().Add(2 * time.Second)
+		for !ok() && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if s := p.Stats(); !ok() {
+			t.Fatalf("created %d connections with IdleCount/OpeningConn = %d/%d, want at least %d created and %d/%d",
+				atomic.LoadInt32(factoryCalls), s.IdleCount, s.OpeningConn, created, floor, floor)
+		}
+	}
+
+	//存活检测失败与空闲超时都不会让空闲连接数低于 InitialCap
+	cfg := newTestConfig()
+	var calls int32
+	cfg.Factory = func() (interface{}, error) { return &testConn{id: int(atomic.AddInt32(&calls, 1))}, nil }
+	cfg.InitialCap = 3
+	cfg.IdleTimeout = 20 * time.Millisecond
+	cfg.MaintainInterval = 5 * time.Millisecond
+	cfg.HealthCheck = func(conn interface{}) error {
+		if conn.(*testConn).id <= 3 {
+			return errors.New("dead")
+		}
+		return nil
+	}
+	cfg.HealthCheckInterval = 5 * time.Millisecond
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	pollFloor(p, &calls, 6, 3)
+	time.Sleep(100 * time.Millisecond)
+	pollFloor(p, &calls, 9, 3)
+
+	//超过最大存活时间的空闲连接由后台协程替换
+	cfg = newTestConfig()
+	var lifetimeCalls int32
+	cfg.Factory = func() (interface{}, error) { return &testConn{id: int(atomic.AddInt32(&lifetimeCalls, 1))}, nil }
+	cfg.InitialCap = 2
+	cfg.IdleTimeout = 0
+	cfg.MaxLifetime = 20 * time.Millisecond
+	cfg.MaintainInterval = 5 * time.Millisecond
+	lp, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer lp.Shutdown()
+	pollFloor(lp, &lifetimeCalls, 4, 2)
+}
+
+func TestReconfigure(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 4
+	cfg.MaxIdle = 4
+	cfg.WaitTimeout = time.Second
+	var oldClosed, newClosed, wrongClose int32
+	cfg.Close = func(conn interface{}) error {
+		if conn.(*testConn).id >= 1000 {
+			atomic.AddInt32(&wrongClose, 1)
+		}
+		atomic.AddInt32(&oldClosed, 1)
+		return nil
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if conn, err := p.Get(); err == nil {
+					_ = p.Put(conn)
+				}
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	next := *cfg
+	next.MaxCap = 1
+	next.MaxIdle = 1
+	next.WaitTimeout = 20 * time.Millisecond
+	var id int32 = 1000
+	next.Factory = func() (interface{}, error) { return &testConn{id: int(atomic.AddInt32(&id, 1))}, nil }
+	next.Close = func(conn interface{}) error {
+		if conn.(*testConn).id < 1000 {
+			atomic.AddInt32(&wrongClose, 1)
+		}
+		atomic.AddInt32(&newClosed, 1)
+		return nil
+	}
+	if err := p.Reconfigure(&next); err != nil {
+		t.Fatalf("Reconfigure: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	//不合法的配置不会修改任何配置
+	bad := next
+	bad.MaxIdle = 2
+	bad.WaitTimeout = time.Minute
+	if err := p.Reconfigure(&bad); !errors.Is(err, InvalidCapSet) {
+		t.Fatalf("Reconfigure with MaxIdle > MaxCap: got %v, want InvalidCapSet", err)
+	}
+
+	//关闭调小 MaxCap 前创建的多余连接 之后的等待按新的 WaitTimeout 超时
+	for p.Len() > 1 {
+		conn, err := p.TryGet()
+		if err != nil {
+			t.Fatalf("TryGet: %v", err)
+		}
+		_ = p.Invalidate(conn)
+	}
+	held, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	start := time.Now()
+	if _, err := p.Get(); !errors.Is(err, GetConnectionTimeout) {
+		t.Fatalf("Get with MaxCap 1 exhausted: got %v, want GetConnectionTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Get waited %v, want the reconfigured 20ms WaitTimeout", elapsed)
+	}
+	_ = p.Invalidate(held)
+
+	//新建的连接使用新的 Close 关闭 已有连接仍使用原来的 Close
+	if conn, err := p.Get(); err != nil {
+		t.Fatalf("Get: %v", err)
+	} else if conn.(*testConn).id <= 1000 {
+		t.Fatalf("Get after Reconfigure returned connection %d, want one from the new Factory", conn.(*testConn).id)
+	} else {
+		_ = p.Put(conn)
+	}
+	_ = p.Shutdown()
+	if atomic.LoadInt32(&newClosed) == 0 || atomic.LoadInt32(&oldClosed) == 0 {
+		t.Fatalf("old/new Close called %d/%d times, want both used", atomic.LoadInt32(&oldClosed), atomic.LoadInt32(&newClosed))
+	}
+	if n := atomic.LoadInt32(&wrongClose); n != 0 {
+		t.Fatalf("%d connections closed with the Close of another Factory", n)
+	}
+}
+
+func TestReconfigureRejectedKeepsState(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxIdle = 2
+	cfg.IdleOverflow = true
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	_ = p.Put(conn)
+
+	//cfg 本身合法 但连接池创建时开启了 IdleOverflow MaxIdle 不能为0
+	next := *cfg
+	next.IdleOverflow = false
+	next.MaxIdle = 0
+	next.MaxCap = 3
+	next.WaitTimeout = time.Minute
+	before := p.Stats()
+	if err := p.Reconfigure(&next); err != InvalidCapSet {
+		t.Fatalf("Reconfigure: got %v, want InvalidCapSet", err)
+	}
+	if after := p.Stats(); !reflect.DeepEqual(before, after) {
+		t.Fatalf("Stats changed by rejected Reconfigure: before %+v after %+v", before, after)
+	}
+	c := p.(*connectionPool)
+	if got := c.loadSettings().waitTimeOut; got != cfg.WaitTimeout {
+		t.Fatalf("WaitTimeout = %v, want %v", got, cfg.WaitTimeout)
+	}
+	c.idleMu.Lock()
+	l := c.loadLimits()
+	c.idleMu.Unlock()
+	if l != configLimits(cfg) {
+		t.Fatalf("limits = %+v, want %+v", l, configLimits(cfg))
+	}
+}
+
+func TestReconfigureBurstCap(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 2
+	cfg.MaxIdle = 2
+	cfg.BurstCap = 3
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	//新的 MaxCap 不小于旧的 BurstCap 同时修改两者时不会与旧值比较
+	next := *cfg
+	next.MaxCap = 4
+	next.BurstCap = 6
+	if err := p.Reconfigure(&next); err != nil {
+		t.Fatalf("Reconfigure: %v", err)
+	}
+	for i := 0; i < 6; i++ {
+		if _, err := p.TryGet(); err != nil {
+			t.Fatalf("TryGet %d: %v", i, err)
+		}
+	}
+	if _, err := p.TryGet(); err != ErrPoolExhausted {
+		t.Fatalf("TryGet beyond BurstCap: got %v, want ErrPoolExhausted", err)
+	}
+	if got := p.Stats().BurstConn; got != 2 {
+		t.Fatalf("BurstConn = %d, want 2", got)
+	}
+}
+
+func TestMaxValidateFailures(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.InitialCap = 5
+	cfg.MaxValidateFailures = 2
+	var validated, closed int32
+	cfg.Validate = func(interface{}) error {
+		atomic.AddInt32(&validated, 1)
+		return errors.New("broken")
+	}
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	start := time.Now()
+	if _, err := p.Get(); err != ErrNoHealthyConnections {
+		t.Fatalf("Get with every connection broken: got %v, want ErrNoHealthyConnections", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("Get returned after %v, want a prompt failure", elapsed)
+	}
+	if v, c := atomic.LoadInt32(&validated), atomic.LoadInt32(&closed); v != 2 || c != 2 {
+		t.Fatalf("validated/closed %d/%d connections, want 2/2", v, c)
+	}
+	if s := p.Stats(); s.IdleCount != 3 || s.OpeningConn != 3 {
+		t.Fatalf("IdleCount/OpeningConn = %d/%d, want 3/3", s.IdleCount, s.OpeningConn)
+	}
+}
+
+func TestFairGetMany(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 4
+	cfg.MaxIdle = 4
+	cfg.WaitTimeout = 2 * time.Second
+	cfg.FairGetMany = true
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	stop := make(chan struct{})
+	var maxWait int64
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				start := time.Now()
+				conn, err := p.Get()
+				if err != nil {
+					t.Errorf("Get: %v", err)
+					return
+				}
+				if d := int64(time.Since(start)); d > atomic.LoadInt64(&maxWait) {
+					atomic.StoreInt64(&maxWait, d)
+				}
+				time.Sleep(time.Millisecond)
+				p.Put(conn)
+			}
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		conns, err := p.GetMany(context.Background(), 3)
+		if err != nil {
+			t.Fatalf("GetMany: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+		for _, conn := range conns {
+			p.Put(conn)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if d := time.Duration(atomic.LoadInt64(&maxWait)); d > 200*time.Millisecond {
+		t.Fatalf("longest single Get waited %v alongside GetMany, want at most 200ms", d)
+	}
+}
+
+func TestNoIdle(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 2
+	cfg.MaxIdle = 0
+	cfg.NoIdle = true
+	var created, closed int32
+	factory := cfg.Factory
+	cfg.Factory = func() (interface{}, error) { atomic.AddInt32(&created, 1); return factory() }
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	for i := 1; i <= 5; i++ {
+		conn, err := p.Get()
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if err := p.Put(conn); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if c, d := atomic.LoadInt32(&created), atomic.LoadInt32(&closed); c != int32(i) || d != int32(i) {
+			t.Fatalf("after %d Get/Put: created/closed %d/%d, want %d/%d", i, c, d, i, i)
+		}
+	}
+	if n := p.IdleLen(); n != 0 {
+		t.Fatalf("IdleLen = %d, want 0", n)
+	}
+
+	//连接数已满时等待的请求得到新创建的连接 而不是被归还的连接
+	a, _ := p.Get()
+	b, _ := p.Get()
+	got := make(chan any, 1)
+	go func() {
+		conn, err := p.Get()
+		if err != nil {
+			t.Errorf("waiting Get: %v", err)
+		}
+		got <- conn
+	}()
+	waitFor(t, func() bool { return p.Stats().WaitingRequests == 1 })
+	p.Put(a)
+	c := <-got
+	if c == a || c == b {
+		t.Fatal("waiting Get received a reused connection")
+	}
+	if n, d := atomic.LoadInt32(&created), atomic.LoadInt32(&closed); n != 8 || d != 6 {
+		t.Fatalf("created/closed %d/%d, want 8/6", n, d)
+	}
+	p.Put(b)
+	p.Put(c)
+}
+
+func TestWaiters(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 2
+	cfg.MaxIdle = 2
+	cfg.WaitTimeout = 300 * time.Millisecond
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	a, _ := p.Get()
+	b, _ := p.Get()
+	results := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			conn, err := p.Get()
+			if err == nil {
+				//持有连接 保证第三个请求超时
+				time.Sleep(500 * time.Millisecond)
+				p.Put(conn)
+			}
+			results <- err
+		}()
+	}
+	waitFor(t, func() bool { return p.Waiters() == 3 })
+	if s := p.Stats(); s.Waiters != 3 {
+		t.Fatalf("Stats().Waiters = %d, want 3", s.Waiters)
+	}
+
+	p.Put(a)
+	p.Put(b)
+	waitFor(t, func() bool { return p.Waiters() == 1 })
+	if err := <-results; !errors.Is(err, GetConnectionTimeout) {
+		t.Fatalf("third waiter: got %v, want GetConnectionTimeout", err)
+	}
+	if n := p.Waiters(); n != 0 {
+		t.Fatalf("Waiters = %d after every waiter returned, want 0", n)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-results; err != nil {
+			t.Fatalf("served waiter: %v", err)
+		}
+	}
+}
+
+func TestCreateTimeout(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	cfg.CreateTimeout = 50 * time.Millisecond
+	cfg.WaitTimeout = 5 * time.Second
+	var closed int32
+	release := make(chan struct{})
+	factory := cfg.Factory
+	cfg.Factory = func() (interface{}, error) {
+		<-release
+		return factory()
+	}
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	start := time.Now()
+	if _, err := p.Get(); err != ErrCreateTimeout {
+		t.Fatalf("Get with a hanging factory: got %v, want ErrCreateTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Get returned after %v, want about CreateTimeout", elapsed)
+	}
+	if s := p.Stats(); s.OpeningConn != 0 || s.ActiveCount != 0 {
+		t.Fatalf("OpeningConn/ActiveCount = %d/%d after timeout, want 0/0", s.OpeningConn, s.ActiveCount)
+	}
+
+	//超时后才创建成功的连接被关闭 连接数已经释放 下一次 Get 可以创建连接
+	close(release)
+	waitFor(t, func() bool { return atomic.LoadInt32(&closed) == 1 })
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get after factory recovered: %v", err)
+	}
+	p.Put(conn)
+}
+
+func TestWaiterServedAfterFactoryFailure(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	cfg.WaitTimeout = 0
+	errDial := errors.New("dial failed")
+	release := make(chan struct{})
+	var calls int32
+	factory := cfg.Factory
+	cfg.Factory = func() (interface{}, error) {
+		//第一次创建阻塞到 release 后失败 之后的创建成功
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-release
+			return nil, errDial
+		}
+		return factory()
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	failed := make(chan error, 1)
+	go func() {
+		_, err := p.Get()
+		failed <- err
+	}()
+	waitFor(t, func() bool { return atomic.LoadInt32(&calls) == 1 })
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	got := make(chan error, 1)
+	go func() {
+		conn, err := p.GetContext(ctx)
+		if err == nil {
+			err = p.Put(conn)
+		}
+		got <- err
+	}()
+	waitFor(t, func() bool { return p.Waiters() == 1 })
+
+	close(release)
+	if err := <-failed; err != errDial {
+		t.Fatalf("Get with a failing factory: got %v, want %v", err, errDial)
+	}
+	//释放的连接数为等待中的请求创建新连接
+	if err := <-got; err != nil {
+		t.Fatalf("waiting Get after factory failure: %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("factory called %d times, want 2", n)
+	}
+}
+
+func TestCreateTimeoutServesWaiter(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	cfg.CreateTimeout = 50 * time.Millisecond
+	cfg.WaitTimeout = 0
+	errDial := errors.New("dial failed")
+	release := make(chan struct{})
+	defer close(release)
+	var calls int32
+	factory := cfg.Factory
+	cfg.Factory = func() (interface{}, error) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			<-release
+		case 4:
+			return nil, errDial
+		}
+		return factory()
+	}
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	getAsync := func() <-chan error {
+		got := make(chan error, 1)
+		go func() {
+			conn, err := p.GetContext(ctx)
+			if err == nil {
+				err = p.Invalidate(conn)
+			}
+			got <- err
+		}()
+		return got
+	}
+
+	//第一次创建超时 释放的连接数为等待中的请求创建连接
+	failed := make(chan error, 1)
+	go func() {
+		_, err := p.Get()
+		failed <- err
+	}()
+	waitFor(t, func() bool { return atomic.LoadInt32(&calls) == 1 })
+	served := getAsync()
+	if err := <-failed; err != ErrCreateTimeout {
+		t.Fatalf("Get with a hanging factory: got %v, want ErrCreateTimeout", err)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("waiting Get after create timeout: %v", err)
+	}
+
+	//为等待中的请求创建连接失败时 错误交给该请求 不会一直等待
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	stranded := getAsync()
+	waitFor(t, func() bool { return p.Waiters() == 1 })
+	_ = p.Invalidate(conn)
+	if err := <-stranded; err != errDial {
+		t.Fatalf("waiting Get after a failed refill: got %v, want %v", err, errDial)
+	}
+}
+
+//closerConn 实现了 io.Closer 的连接
+type closerConn struct {
+	closed *int32
+}
+
+func (c *closerConn) Close() error {
+	atomic.AddInt32(c.closed, 1)
+	return nil
+}
+
+func TestCloseFallback(t *testing.T) {
+	t.Run("io.Closer", func(t *testing.T) {
+		var closed int32
+		cfg := newTestConfig()
+		cfg.Factory = func() (interface{}, error) { return &closerConn{closed: &closed}, nil }
+		cfg.Close = nil
+		p, err := NewPool(cfg)
+		if err != nil {
+			t.Fatalf("NewPool without Close: %v", err)
+		}
+		defer p.Shutdown()
+		conn, _ := p.Get()
+		if err := p.CloseConn(conn); err != nil {
+			t.Fatalf("CloseConn: %v", err)
+		}
+		if n := atomic.LoadInt32(&closed); n != 1 {
+			t.Fatalf("io.Closer called %d times, want 1", n)
+		}
+	})
+	t.Run("explicit func", func(t *testing.T) {
+		var closed, funcClosed int32
+		cfg := newTestConfig()
+		cfg.Factory = func() (interface{}, error) { return &closerConn{closed: &closed}, nil }
+		cfg.Close = func(interface{}) error { atomic.AddInt32(&funcClosed, 1); return nil }
+		p, err := NewPool(cfg)
+		if err != nil {
+			t.Fatalf("NewPool: %v", err)
+		}
+		defer p.Shutdown()
+		conn, _ := p.Get()
+		if err := p.CloseConn(conn); err != nil {
+			t.Fatalf("CloseConn: %v", err)
+		}
+		if c, f := atomic.LoadInt32(&closed), atomic.LoadInt32(&funcClosed); c != 0 || f != 1 {
+			t.Fatalf("io.Closer/Close func called %d/%d times, want 0/1", c, f)
+		}
+	})
+	t.Run("neither", func(t *testing.T) {
+		cfg := newTestConfig()
+		cfg.Close = nil
+		p, err := NewPool(cfg)
+		if err != nil {
+			t.Fatalf("NewPool: %v", err)
+		}
+		defer p.Shutdown()
+		conn, _ := p.Get()
+		if err := p.CloseConn(conn); err != InvalidCloseSet {
+			t.Fatalf("CloseConn of a non-Closer without Close: got %v, want InvalidCloseSet", err)
+		}
+		if n := p.Len(); n != 0 {
+			t.Fatalf("Len = %d after failed close, want 0", n)
+		}
+	})
+}
+
+func TestUse(t *testing.T) {
+	var closed int32
+	cfg := newTestConfig()
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	var used any
+	if err := p.Use(context.Background(), func(conn any) error { used = conn; return nil }); err != nil {
+		t.Fatalf("Use: %v", err)
+	}
+	if p.ActiveLen() != 0 || p.IdleLen() != 1 {
+		t.Fatalf("ActiveLen/IdleLen = %d/%d after Use, want 0/1", p.ActiveLen(), p.IdleLen())
+	}
+
+	errFn := errors.New("query failed")
+	if err := p.Use(context.Background(), func(conn any) error { return errFn }); err != errFn {
+		t.Fatalf("Use returning an error: got %v, want %v", err, errFn)
+	}
+	if p.ActiveLen() != 0 || p.IdleLen() != 1 || atomic.LoadInt32(&closed) != 0 {
+		t.Fatal("connection was not returned after fn failed")
+	}
+
+	broken := fmt.Errorf("read: %w", ErrBrokenConn)
+	if err := p.Use(context.Background(), func(conn any) error { return broken }); err != broken {
+		t.Fatalf("Use returning ErrBrokenConn: got %v, want %v", err, broken)
+	}
+	if p.Len() != 0 || atomic.LoadInt32(&closed) != 1 {
+		t.Fatalf("Len/closed = %d/%d after ErrBrokenConn, want 0/1", p.Len(), atomic.LoadInt32(&closed))
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("recovered %v, want the panic from fn", r)
+			}
+		}()
+		_ = p.Use(context.Background(), func(conn any) error { used = conn; panic("boom") })
+	}()
+	if p.ActiveLen() != 0 || p.IdleLen() != 1 {
+		t.Fatalf("ActiveLen/IdleLen = %d/%d after fn panicked, want 0/1", p.ActiveLen(), p.IdleLen())
+	}
+	if conn, _ := p.TryGet(); conn != used {
+		t.Fatal("connection returned after the panic is not reused")
+	}
+}
+
+func TestErrorsIs(t *testing.T) {
+	if _, err := NewPool(&Config{MaxCap: 1, MaxIdle: 2}); !errors.Is(err, ErrInvalidCapSet) || !errors.Is(err, ErrInvalidFactorySet) {
+		t.Fatalf("NewPool with invalid config: got %v, want ErrInvalidCapSet and ErrInvalidFactorySet", err)
+	}
+
+	errDial := errors.New("dial refused")
+	errClose := errors.New("close failed")
+	failDial := int32(0)
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	cfg.WaitTimeout = 20 * time.Millisecond
+	factory := cfg.Factory
+	cfg.Factory = func() (interface{}, error) {
+		if atomic.LoadInt32(&failDial) == 1 {
+			return nil, fmt.Errorf("connect 10.0.0.1: %w", errDial)
+		}
+		return factory()
+	}
+	cfg.Close = func(interface{}) error { return errClose }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+
+	atomic.StoreInt32(&failDial, 1)
+	if _, err := p.Get(); !errors.Is(err, errDial) {
+		t.Fatalf("Get with failing factory: got %v, want it to wrap the factory error", err)
+	}
+	atomic.StoreInt32(&failDial, 0)
+
+	conn, _ := p.Get()
+	if _, err := p.TryGet(); !errors.Is(err, ErrPoolExhausted) {
+		t.Fatalf("TryGet: got %v, want ErrPoolExhausted", err)
+	}
+	_, err = p.Get()
+	var timeoutErr *WaitTimeoutError
+	if !errors.Is(err, ErrGetConnectionTimeout) || !errors.As(err, &timeoutErr) {
+		t.Fatalf("Get: got %v, want a *WaitTimeoutError matching ErrGetConnectionTimeout", err)
+	}
+	if timeoutErr.Timeout != cfg.WaitTimeout || timeoutErr.Waited < cfg.WaitTimeout {
+		t.Fatalf("WaitTimeoutError Timeout/Waited = %v/%v, want %v and at least as long", timeoutErr.Timeout, timeoutErr.Waited, cfg.WaitTimeout)
+	}
+
+	if err := p.Put(nil); !errors.Is(err, ErrConnectionIsNull) {
+		t.Fatalf("Put(nil): got %v, want ErrConnectionIsNull", err)
+	}
+	if err := p.Put(&testConn{id: -1}); !errors.Is(err, ErrUnknownConnection) {
+		t.Fatalf("Put of a foreign connection: got %v, want ErrUnknownConnection", err)
+	}
+	if err := p.Put(conn); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	//空闲连接的关闭错误通过 Shutdown 返回
+	if err := p.Shutdown(); !errors.Is(err, errClose) {
+		t.Fatalf("Shutdown: got %v, want it to wrap the close error", err)
+	}
+	if _, err := p.Get(); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("Get after Shutdown: got %v, want ErrPoolClosed", err)
+	}
+}
+
+func TestDrainIdle(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.InitialCap = 3
+	var created, closed int32
+	factory := cfg.Factory
+	cfg.Factory = func() (interface{}, error) { atomic.AddInt32(&created, 1); return factory() }
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	borrowed, _ := p.Get()
+	if err := p.DrainIdle(); err != nil {
+		t.Fatalf("DrainIdle: %v", err)
+	}
+	if s := p.Stats(); s.IdleCount != 0 || s.OpeningConn != 1 || s.ActiveCount != 1 {
+		t.Fatalf("IdleCount/OpeningConn/ActiveCount = %d/%d/%d after DrainIdle, want 0/1/1", s.IdleCount, s.OpeningConn, s.ActiveCount)
+	}
+	if n := atomic.LoadInt32(&closed); n != 2 {
+		t.Fatalf("DrainIdle closed %d connections, want the 2 idle ones", n)
+	}
+	if p.IsClosed() {
+		t.Fatal("IsClosed = true after DrainIdle")
+	}
+
+	before := atomic.LoadInt32(&created)
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get after DrainIdle: %v", err)
+	}
+	if atomic.LoadInt32(&created) != before+1 || conn == borrowed {
+		t.Fatal("Get after DrainIdle did not create a fresh connection")
+	}
+	if err := p.Put(borrowed); err != nil {
+		t.Fatalf("Put of a connection borrowed before DrainIdle: %v", err)
+	}
+	p.Put(conn)
+
+	p.Shutdown()
+	if err := p.DrainIdle(); err != PoolClosed {
+		t.Fatalf("DrainIdle after Shutdown: got %v, want PoolClosed", err)
+	}
+}
+
+func TestReset(t *testing.T) {
+	cfg := newTestConfig()
+	var resets, closed int32
+	cfg.Reset = func(conn interface{}) error {
+		atomic.AddInt32(&resets, 1)
+		if conn.(*testConn).id == 1 {
+			return errors.New("rollback failed")
+		}
+		return nil
+	}
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	a, _ := p.Get()
+	b, _ := p.Get()
+	if err := p.Put(a); err != nil {
+		t.Fatalf("Put of a connection that fails Reset: %v", err)
+	}
+	if err := p.Put(b); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if r, c := atomic.LoadInt32(&resets), atomic.LoadInt32(&closed); r != 2 || c != 1 {
+		t.Fatalf("reset/closed %d/%d connections, want 2/1", r, c)
+	}
+	if s := p.Stats(); s.IdleCount != 1 || s.OpeningConn != 1 {
+		t.Fatalf("IdleCount/OpeningConn = %d/%d, want 1/1", s.IdleCount, s.OpeningConn)
+	}
+	//重置失败的连接不会交给下一个请求
+	for i := 0; i < 2; i++ {
+		conn, _ := p.Get()
+		if conn == a {
+			t.Fatal("Get returned the connection whose Reset failed")
+		}
+		defer p.Put(conn)
+	}
+}
+
+func TestBurstCap(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 2
+	cfg.MaxIdle = 2
+	cfg.BurstCap = 3
+	cfg.WaitTimeout = 50 * time.Millisecond
+	var closed int32
+	cfg.Close = func(interface{}) error { atomic.AddInt32(&closed, 1); return nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	var conns []any
+	for i := 0; i < 3; i++ {
+		conn, err := p.Get()
+		if err != nil {
+			t.Fatalf("Get %d: %v", i, err)
+		}
+		conns = append(conns, conn)
+	}
+	if s := p.Stats(); s.OpeningConn != 3 || s.BurstConn != 1 || s.TotalBursts != 1 {
+		t.Fatalf("OpeningConn/BurstConn/TotalBursts = %d/%d/%d, want 3/1/1", s.OpeningConn, s.BurstConn, s.TotalBursts)
+	}
+	//超出 BurstCap 后与达到 MaxCap 时一样等待
+	if _, err := p.Get(); !errors.Is(err, ErrGetConnectionTimeout) {
+		t.Fatalf("Get beyond BurstCap: got %v, want timeout", err)
+	}
+
+	//突发连接归还时被关闭 普通连接放入空闲队列
+	for _, conn := range conns {
+		if err := p.Put(conn); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&closed); n != 1 {
+		t.Fatalf("closed %d connections, want the single burst connection", n)
+	}
+	if s := p.Stats(); s.IdleCount != 2 || s.OpeningConn != 2 || s.BurstConn != 0 {
+		t.Fatalf("IdleCount/OpeningConn/BurstConn = %d/%d/%d, want 2/2/0", s.IdleCount, s.OpeningConn, s.BurstConn)
+	}
+	//MaxCap 必须保持小于 BurstCap
+	if err := p.SetMaxCap(3); err != InvalidCapSet {
+		t.Fatalf("SetMaxCap to BurstCap: got %v, want InvalidCapSet", err)
+	}
+}
+
+func TestBurstCapServesWaiter(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 1
+	cfg.MaxIdle = 1
+	cfg.BurstCap = 2
+	cfg.WaitTimeout = 2 * time.Second
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	normal, _ := p.Get()
+	burst, _ := p.Get()
+	got := make(chan any, 1)
+	go func() {
+		conn, err := p.Get()
+		if err != nil {
+			t.Errorf("waiting Get: %v", err)
+		}
+		got <- conn
+	}()
+	waitFor(t, func() bool { return p.Waiters() == 1 })
+
+	//突发连接归还时被关闭 空出的突发连接数为等待中的请求创建连接
+	_ = p.Put(burst)
+	select {
+	case conn := <-got:
+		_ = p.Put(conn)
+	case <-time.After(time.Second):
+		t.Fatal("waiter was not served from the freed burst slot")
+	}
+	_ = p.Put(normal)
+	if s := p.Stats(); s.TotalBursts != 2 || s.BurstConn != 0 || s.IdleCount != 1 || s.OpeningConn != 1 {
+		t.Fatalf("TotalBursts/BurstConn/IdleCount/OpeningConn = %d/%d/%d/%d, want 2/0/1/1", s.TotalBursts, s.BurstConn, s.IdleCount, s.OpeningConn)
+	}
+}
+
+func TestPeakActive(t *testing.T) {
+	p, err := NewPool(newTestConfig())
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	//6 个协程同时持有连接 全部获取后才归还
+	const concurrency = 6
+	var held sync.WaitGroup
+	held.Add(concurrency)
+	release := make(chan struct{})
+	var done sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			conn, err := p.Get()
+			held.Done()
+			if err != nil {
+				t.Errorf("Get: %v", err)
+				return
+			}
+			<-release
+			p.Put(conn)
+		}()
+	}
+	held.Wait()
+	close(release)
+	done.Wait()
+	if s := p.Stats(); s.PeakActive != concurrency || s.ActiveCount != 0 {
+		t.Fatalf("PeakActive/ActiveCount = %d/%d, want %d/0", s.PeakActive, s.ActiveCount, concurrency)
+	}
+
+	p.ResetPeak()
+	if peak := p.Stats().PeakActive; peak != 0 {
+		t.Fatalf("PeakActive = %d after ResetPeak, want 0", peak)
+	}
+	a, _ := p.Get()
+	b, _ := p.Get()
+	p.Put(a)
+	p.Put(b)
+	if peak := p.Stats().PeakActive; peak != 2 {
+		t.Fatalf("PeakActive = %d in the new window, want 2", peak)
+	}
+}
+
+func TestGetCancelledContext(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.InitialCap = 1
+	var created int32
+	factory := cfg.Factory
+	cfg.Factory = func() (interface{}, error) { atomic.AddInt32(&created, 1); return factory() }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	//有空闲连接时同样不借出
+	if _, err := p.GetContext(ctx); err != context.Canceled {
+		t.Fatalf("GetContext with idle connection: got %v, want context.Canceled", err)
+	}
+	idle, _ := p.Get()
+	//没有空闲连接时不会创建连接
+	if _, err := p.GetWithPriority(ctx, 1); err != context.Canceled {
+		t.Fatalf("GetWithPriority without idle connection: got %v, want context.Canceled", err)
+	}
+	if n := atomic.LoadInt32(&created); n != 1 {
+		t.Fatalf("factory called %d times, want only the warm-up call", n)
+	}
+	if s := p.Stats(); s.ActiveCount != 1 || s.TotalGets != 1 {
+		t.Fatalf("ActiveCount/TotalGets = %d/%d, want 1/1", s.ActiveCount, s.TotalGets)
+	}
+	p.Put(idle)
+}
+
+func TestBorrowed(t *testing.T) {
+	clk := newFakeClock()
+	cfg := newTestConfig()
+	cfg.clock = clk
+	cfg.Factory = func() (interface{}, error) { return &metaConn{version: "v1"}, nil }
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	a, _ := p.Get()
+	clk.Advance(3 * time.Second)
+	b, _ := p.Get()
+	clk.Advance(time.Second)
+
+	borrowed := p.Borrowed()
+	if len(borrowed) != 2 {
+		t.Fatalf("Borrowed returned %d entries, want 2", len(borrowed))
+	}
+	sort.Slice(borrowed, func(i, j int) bool { return borrowed[i].Age < borrowed[j].Age })
+	if borrowed[0].Age != time.Second || borrowed[1].Age != 4*time.Second {
+		t.Fatalf("Borrowed ages = %v/%v, want 1s/4s", borrowed[0].Age, borrowed[1].Age)
+	}
+	if borrowed[0].Meta["version"] != "v1" {
+		t.Fatalf("Borrowed meta = %v, want the connection meta", borrowed[0].Meta)
+	}
+
+	//返回值是副本 归还后不受影响
+	_ = p.Put(a)
+	_ = p.Put(b)
+	if len(borrowed) != 2 || len(p.Borrowed()) != 0 {
+		t.Fatalf("Borrowed after Put = %d entries, want 0", len(p.Borrowed()))
+	}
+}
+
+//sliceConn 不可比较的测试连接
+type sliceConn struct {
+	id  int
+	buf []byte
+}
+
+func TestConnKey(t *testing.T) {
+	newSlicePool := func(keyFunc func(any) any) Pool {
+		cfg := newTestConfig()
+		var id int32
+		cfg.Factory = func() (interface{}, error) {
+			return sliceConn{id: int(atomic.AddInt32(&id, 1)), buf: make([]byte, 1)}, nil
+		}
+		cfg.KeyFunc = keyFunc
+		p, err := NewPool(cfg)
+		if err != nil {
+			t.Fatalf("NewPool: %v", err)
+		}
+		return p
+	}
+	//check 借出两个连接 依次归还 重复归还与归还未借出的连接都被拒绝
+	check := func(name string, p Pool, foreign any) {
+		defer p.Shutdown()
+		a, err := p.Get()
+		if err != nil {
+			t.Fatalf("%s: Get: %v", name, err)
+		}
+		b, err := p.Get()
+		if err != nil {
+			t.Fatalf("%s: Get: %v", name, err)
+		}
+		if err := p.Put(a); err != nil {
+			t.Fatalf("%s: Put: %v", name, err)
+		}
+		if err := p.Put(a); err != ErrUnknownConnection {
+			t.Fatalf("%s: second Put: got %v, want ErrUnknownConnection", name, err)
+		}
+		if err := p.Put(foreign); err != ErrUnknownConnection {
+			t.Fatalf("%s: Put of a foreign connection: got %v, want ErrUnknownConnection", name, err)
+		}
+		if n := len(p.Borrowed()); n != 1 {
+			t.Fatalf("%s: Borrowed = %d entries, want 1", name, n)
+		}
+		if err := p.Invalidate(b); err != nil {
+			t.Fatalf("%s: Invalidate: %v", name, err)
+		}
+		if p.ActiveLen() != 0 || p.IdleLen() != 1 {
+			t.Fatalf("%s: ActiveLen/IdleLen = %d/%d, want 0/1", name, p.ActiveLen(), p.IdleLen())
+		}
+	}
+
+	comparable, err := NewPool(newTestConfig())
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	check("comparable", comparable, &testConn{})
+	check("non-comparable", newSlicePool(nil), sliceConn{id: 99})
+	check("KeyFunc", newSlicePool(func(conn any) any { return conn.(sliceConn).id }), sliceConn{id: 99})
+
+	//分片连接池同样按 KeyFunc 记录连接所属的分片
+	cfg := newTestConfig()
+	var next int32
+	cfg.Factory = func() (interface{}, error) {
+		return sliceConn{id: int(atomic.AddInt32(&next, 1)), buf: make([]byte, 1)}, nil
+	}
+	cfg.KeyFunc = func(conn any) any { return conn.(sliceConn).id }
+	sp, err := NewShardedPool(cfg, 2)
+	if err != nil {
+		t.Fatalf("NewShardedPool: %v", err)
+	}
+	check("sharded", sp, sliceConn{id: 99})
+}
+
+//boxConn 类型可比较 但字段可能保存不可比较的值
+type boxConn struct {
+	v any
+}
+
+func TestConnKeyIdentity(t *testing.T) {
+	//内容相同的不可比较连接 以及字段保存了不可比较值的可比较类型 都按 Get 返回的值区分
+	for name, newConn := range map[string]func() any{
+		"equal content":   func() any { return sliceConn{buf: make([]byte, 1)} },
+		"interface field": func() any { return boxConn{v: make([]byte, 1)} },
+	} {
+		bufOf := func(conn any) *byte {
+			if c, ok := conn.(sliceConn); ok {
+				return &c.buf[0]
+			}
+			return &conn.(boxConn).v.([]byte)[0]
+		}
+		cfg := newTestConfig()
+		cfg.Factory = func() (interface{}, error) { return newConn(), nil }
+		p, err := NewPool(cfg)
+		if err != nil {
+			t.Fatalf("%s: NewPool: %v", name, err)
+		}
+		a, err := p.Get()
+		if err != nil {
+			t.Fatalf("%s: Get: %v", name, err)
+		}
+		b, err := p.Get()
+		if err != nil {
+			t.Fatalf("%s: Get: %v", name, err)
+		}
+		if err := p.Put(b); err != nil {
+			t.Fatalf("%s: Put: %v", name, err)
+		}
+		if err := p.Put(newConn()); err != ErrUnknownConnection {
+			t.Fatalf("%s: Put of an equal foreign connection: got %v, want ErrUnknownConnection", name, err)
+		}
+		idle, err := p.TryGet()
+		if err != nil {
+			t.Fatalf("%s: TryGet: %v", name, err)
+		}
+		if bufOf(idle) != bufOf(b) {
+			t.Fatalf("%s: idle connection is not the one returned by Put", name)
+		}
+		if err := p.Invalidate(a); err != nil {
+			t.Fatalf("%s: Invalidate: %v", name, err)
+		}
+		if err := p.Put(idle); err != nil {
+			t.Fatalf("%s: Put: %v", name, err)
+		}
+		if p.ActiveLen() != 0 || p.IdleLen() != 1 {
+			t.Fatalf("%s: ActiveLen/IdleLen = %d/%d, want 0/1", name, p.ActiveLen(), p.IdleLen())
+		}
+		_ = p.Shutdown()
+	}
+}
+
+func TestWarmupTimeout(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.InitialCap = 5
+	cfg.WarmupTimeout = 50 * time.Millisecond
+	cfg.Factory = func() (interface{}, error) {
+		time.Sleep(20 * time.Millisecond)
+		return &testConn{}, nil
+	}
+	logger := &captureLogger{}
+	cfg.Logger = logger
+	start := time.Now()
+	p, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	defer p.Shutdown()
+	//最多等待 WarmupTimeout 不等待正在进行的创建
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Fatalf("NewPool took %v, want about the 50ms WarmupTimeout", elapsed)
+	}
+	if n := p.IdleLen(); n < 1 || n >= 5 {
+		t.Fatalf("IdleLen = %d after warmup timeout, want a partially filled pool", n)
+	}
+	logger.mu.Lock()
+	warns := strings.Join(logger.warns, "\n")
+	logger.mu.Unlock()
+	if !strings.Contains(warns, "warmup timed out") {
+		t.Fatalf("no warmup timeout warning logged: %q", warns)
+	}
+
+	//FactoryContext 收到到期的 ctx 时立即结束初始化
+	cfg.Factory = nil
+	cfg.FactoryContext = func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	start = time.Now()
+	blocked, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool with a blocking FactoryContext: %v", err)
+	}
+	defer blocked.Shutdown()
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond || blocked.IdleLen() != 0 {
+		t.Fatalf("NewPool took %v with %d idle connections, want a prompt return with none", elapsed, blocked.IdleLen())
+	}
+
+	//不使用 ctx 的 Factory 一直阻塞时同样按时返回 到期后才创建成功的连接被关闭
+	release := make(chan struct{})
+	var closed int32
+	cfg.FactoryContext = nil
+	cfg.Factory = func() (interface{}, error) {
+		<-release
+		return &testConn{}, nil
+	}
+	cfg.Close = func(interface{}) error {
+		atomic.AddInt32(&closed, 1)
+		return nil
+	}
+	start = time.Now()
+	hung, err := NewPool(cfg)
+	if err != nil {
+		t.Fatalf("NewPool with a blocking Factory: %v", err)
+	}
+	defer hung.Shutdown()
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond || hung.IdleLen() != 0 {
+		t.Fatalf("NewPool took %v with %d idle connections, want a prompt return with none", elapsed, hung.IdleLen())
+	}
+	close(release)
+	waitFor(t, func() bool { return atomic.LoadInt32(&closed) == 1 })
+	if got := hung.Stats().OpeningConn; got != 0 {
+		t.Fatalf("OpeningConn = %d, want the late connection uncounted", got)
+	}
 }
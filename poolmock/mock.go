@@ -0,0 +1,511 @@
+package poolmock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"simpleConnPool"
+)
+
+/*
+====== 用于下游测试的模拟连接池 =======
+*/
+
+//MockPool 实现 simpleConnPool.Pool 的模拟连接池 不连接任何真实后端
+//记录每次调用的方法名 支持注入 Get 与 Put 的错误 并跟踪未归还的连接以便测试断言没有泄漏
+//factory 返回的连接必须可以作为 map 的 key
+type MockPool struct {
+	factory func() any
+
+	mu       sync.Mutex
+	calls    []string          //按调用顺序记录的方法名
+	getErr   error             //注入的获取连接错误
+	putErr   error             //注入的归还连接错误
+	idle     []any             //已归还可复用的连接
+	borrowed map[any]time.Time //已借出未归还的连接 value 为借出时间
+	closed   bool
+	maxCap   int32
+	maxIdle  int32 //小于0表示不限制
+	gets     int64 //累计成功获取连接次数
+	peak     int32 //同时借出连接数的峰值
+	events   chan simpleConnPool.Event
+}
+
+var _ simpleConnPool.Pool = (*MockPool)(nil)
+
+//New 构造一个模拟连接池 没有可复用的连接时调用 factory 创建连接
+func New(factory func() any) *MockPool {
+	return &MockPool{
+		factory:  factory,
+		borrowed: make(map[any]time.Time),
+		maxIdle:  -1,
+		events:   make(chan simpleConnPool.Event),
+	}
+}
+
+//FailGetWith 之后所有获取连接的方法都返回 err err 为 nil 时恢复正常
+func (m *MockPool) FailGetWith(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.getErr = err
+}
+
+//FailPutWith 之后所有 Put 都返回 err 且连接仍视为未归还 err 为 nil 时恢复正常
+func (m *MockPool) FailPutWith(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.putErr = err
+}
+
+//Calls 返回按调用顺序记录的方法名
+func (m *MockPool) Calls() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.calls...)
+}
+
+//CallCount 返回方法 method 被调用的次数
+func (m *MockPool) CallCount(method string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, call := range m.calls {
+		if call == method {
+			n++
+		}
+	}
+	return n
+}
+
+//Outstanding 返回已借出未归还的连接 测试结束时为空表示没有泄漏
+func (m *MockPool) Outstanding() []any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	conns := make([]any, 0, len(m.borrowed))
+	for conn := range m.borrowed {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+//record 记录一次调用 调用方需持有 mu
+func (m *MockPool) record(method string) {
+	m.calls = append(m.calls, method)
+}
+
+//get 记录调用并借出一个连接 ctx 已结束时返回 ctx.Err() created 表示连接由 factory 新创建
+func (m *MockPool) get(ctx context.Context, method string) (conn any, created bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.record(method)
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	return m.borrow()
+}
+
+//borrow 借出一个连接 调用方需持有 mu
+func (m *MockPool) borrow() (conn any, created bool, err error) {
+	if m.closed {
+		return nil, false, simpleConnPool.PoolClosed
+	}
+	if m.getErr != nil {
+		return nil, false, m.getErr
+	}
+	if last := len(m.idle) - 1; last >= 0 {
+		conn = m.idle[last]
+		m.idle = m.idle[:last]
+	} else {
+		if m.maxCap > 0 && int32(len(m.borrowed)) >= m.maxCap {
+			return nil, false, simpleConnPool.ErrPoolExhausted
+		}
+		conn, created = m.factory(), true
+	}
+	m.lend(conn)
+	return conn, created, nil
+}
+
+//lend 将 conn 记录为借出并更新计数 调用方需持有 mu
+func (m *MockPool) lend(conn any) {
+	m.borrowed[conn] = time.Now()
+	m.gets++
+	if n := int32(len(m.borrowed)); n > m.peak {
+		m.peak = n
+	}
+}
+
+//release 记录调用并将 conn 标记为已归还 conn 不是借出的连接时返回错误
+func (m *MockPool) release(method string, conn any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.record(method)
+	return m.forget(conn)
+}
+
+//forget 将 conn 移出借出集合 调用方需持有 mu
+func (m *MockPool) forget(conn any) error {
+	if conn == nil {
+		return simpleConnPool.ConnectionIsNull
+	}
+	if _, ok := m.borrowed[conn]; !ok {
+		return simpleConnPool.ErrUnknownConnection
+	}
+	delete(m.borrowed, conn)
+	return nil
+}
+
+//Get 借出一个连接 没有可复用的连接时调用 factory 创建
+func (m *MockPool) Get() (any, error) {
+	conn, _, err := m.get(context.Background(), "Get")
+	return conn, err
+}
+
+//GetContext 与 Get 相同 ctx 已结束时返回 ctx.Err()
+func (m *MockPool) GetContext(ctx context.Context) (any, error) {
+	conn, _, err := m.get(ctx, "GetContext")
+	return conn, err
+}
+
+//GetWithPriority 与 GetContext 相同 模拟连接池不会等待 因此忽略 priority
+func (m *MockPool) GetWithPriority(ctx context.Context, priority int) (any, error) {
+	conn, _, err := m.get(ctx, "GetWithPriority")
+	return conn, err
+}
+
+//Use 借出一个连接并以它调用 fn fn 返回或 panic 后通过 Put 归还 fn 返回 ErrBrokenConn 时通过 Invalidate 关闭
+func (m *MockPool) Use(ctx context.Context, fn func(conn any) error) (err error) {
+	conn, _, err := m.get(ctx, "Use")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errors.Is(err, simpleConnPool.ErrBrokenConn) {
+			_ = m.Invalidate(conn)
+			return
+		}
+		_ = m.Put(conn)
+	}()
+	return fn(conn)
+}
+
+//GetMany 一次借出 n 个连接 全部借出成功或全部失败
+func (m *MockPool) GetMany(ctx context.Context, n int) ([]any, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.record("GetMany")
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	conns := make([]any, 0, n)
+	for len(conns) < n {
+		conn, _, err := m.borrow()
+		if err != nil {
+			for _, got := range conns {
+				delete(m.borrowed, got)
+				m.idle = append(m.idle, got)
+			}
+			return nil, err
+		}
+		conns = append(conns, conn)
+	}
+	return conns, nil
+}
+
+//GetWithInfo 与 GetContext 相同 同时返回连接是否复用或新创建 模拟连接池不会等待
+func (m *MockPool) GetWithInfo(ctx context.Context) (any, simpleConnPool.GetInfo, error) {
+	conn, created, err := m.get(ctx, "GetWithInfo")
+	info := simpleConnPool.GetInfo{Err: err}
+	if err == nil {
+		info.Created, info.FromIdle = created, !created
+		info.Source = simpleConnPool.SourceIdle
+		if created {
+			info.Source = simpleConnPool.SourceCreated
+		}
+	}
+	return conn, info, err
+}
+
+//TryGet 与 Get 相同
+func (m *MockPool) TryGet() (any, error) {
+	conn, _, err := m.get(context.Background(), "TryGet")
+	return conn, err
+}
+
+//GetWithTimeout 与 Get 相同 模拟连接池不会等待 因此忽略 d
+func (m *MockPool) GetWithTimeout(d time.Duration) (any, error) {
+	conn, _, err := m.get(context.Background(), "GetWithTimeout")
+	return conn, err
+}
+
+//GetConn 借出一个连接并返回连接句柄
+func (m *MockPool) GetConn() (*simpleConnPool.PooledConn, error) {
+	conn, _, err := m.get(context.Background(), "GetConn")
+	if err != nil {
+		return nil, err
+	}
+	return simpleConnPool.NewPooledConn(m, conn), nil
+}
+
+//GetUncounted 与 Get 相同 借出的连接同样需要归还
+func (m *MockPool) GetUncounted() (any, error) {
+	conn, _, err := m.get(context.Background(), "GetUncounted")
+	return conn, err
+}
+
+//Put 归还一个连接或 GetConn 返回的句柄 注入了 Put 错误时返回该错误且连接仍视为未归还
+func (m *MockPool) Put(conn any) error {
+	if pc, ok := conn.(*simpleConnPool.PooledConn); ok {
+		return pc.Release()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.record("Put")
+	if m.putErr != nil {
+		return m.putErr
+	}
+	if err := m.forget(conn); err != nil {
+		return err
+	}
+	if m.closed {
+		return simpleConnPool.PoolClosed
+	}
+	if m.maxIdle < 0 || int32(len(m.idle)) < m.maxIdle {
+		m.idle = append(m.idle, conn)
+	}
+	return nil
+}
+
+//CloseConn 关闭一个借出的连接
+func (m *MockPool) CloseConn(conn any) error {
+	return m.release("CloseConn", conn)
+}
+
+//Close 关闭一个借出的连接
+//
+//Deprecated: 请使用 CloseConn 关闭单个连接 使用 Shutdown 关闭连接池
+func (m *MockPool) Close(conn any) error {
+	return m.release("Close", conn)
+}
+
+//Invalidate 关闭一个已损坏的借出连接
+func (m *MockPool) Invalidate(conn any) error {
+	return m.release("Invalidate", conn)
+}
+
+//RefreshConn 关闭一个借出的连接并借出一个新创建的连接
+func (m *MockPool) RefreshConn(old any) (any, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.record("RefreshConn")
+	if err := m.forget(old); err != nil {
+		return nil, err
+	}
+	if m.closed {
+		return nil, simpleConnPool.PoolClosed
+	}
+	if m.getErr != nil {
+		return nil, m.getErr
+	}
+	conn := m.factory()
+	m.lend(conn)
+	return conn, nil
+}
+
+//Shutdown 关闭模拟连接池 之后获取连接返回 PoolClosed 未归还的连接仍然被跟踪
+func (m *MockPool) Shutdown() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.record("Shutdown")
+	if !m.closed {
+		m.closed = true
+		m.idle = nil
+		close(m.events)
+	}
+	return nil
+}
+
+//DrainContext 关闭模拟连接池并等待所有借出的连接归还 ctx 结束前仍有连接未归还则返回 ErrDrainTimeout
+func (m *MockPool) DrainContext(ctx context.Context) error {
+	_ = m.Shutdown()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for m.ActiveLen() > 0 {
+		select {
+		case <-ctx.Done():
+			return simpleConnPool.ErrDrainTimeout
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+//ShutdownContext 与 DrainContext 相同 ctx 结束前仍有连接未归还时将其移出借出集合并返回 ErrForcedShutdown
+func (m *MockPool) ShutdownContext(ctx context.Context) error {
+	if err := m.DrainContext(ctx); err == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.borrowed) == 0 {
+		return nil
+	}
+	m.borrowed = make(map[any]time.Time)
+	return simpleConnPool.ErrForcedShutdown
+}
+
+//WaitReady 模拟连接池总是就绪 连接池已关闭时返回 PoolClosed
+func (m *MockPool) WaitReady(ctx context.Context) error {
+	if m.IsClosed() {
+		return simpleConnPool.PoolClosed
+	}
+	return ctx.Err()
+}
+
+//Ping 注入了 Get 错误时返回该错误
+func (m *MockPool) Ping(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.record("Ping")
+	if m.closed {
+		return simpleConnPool.PoolClosed
+	}
+	if m.getErr != nil {
+		return m.getErr
+	}
+	return ctx.Err()
+}
+
+//IsClosed 模拟连接池是否已经关闭
+func (m *MockPool) IsClosed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.closed
+}
+
+//Stats 返回模拟连接池当前的状态
+func (m *MockPool) Stats() simpleConnPool.Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats()
+}
+
+//StatsSnapshotAndReset 返回模拟连接池当前的状态 并将累计计数器清零
+func (m *MockPool) StatsSnapshotAndReset() simpleConnPool.Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := m.stats()
+	m.gets = 0
+	return stats
+}
+
+//stats 返回当前的状态 调用方需持有 mu
+func (m *MockPool) stats() simpleConnPool.Stats {
+	return simpleConnPool.Stats{
+		IdleCount:   int32(len(m.idle)),
+		ActiveCount: int32(len(m.borrowed)),
+		PeakActive:  m.peak,
+		OpeningConn: int32(len(m.idle) + len(m.borrowed)),
+		TotalGets:   m.gets,
+	}
+}
+
+//ResetPeak 将同时借出连接数的峰值重置为当前借出的连接数
+func (m *MockPool) ResetPeak() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.peak = int32(len(m.borrowed))
+}
+
+//Events 模拟连接池不会发布事件 返回的 channel 在 Shutdown 时关闭
+func (m *MockPool) Events() <-chan simpleConnPool.Event {
+	return m.events
+}
+
+//Len 返回空闲与借出的连接总数
+func (m *MockPool) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.idle) + len(m.borrowed)
+}
+
+//IdleLen 返回可复用的连接数
+func (m *MockPool) IdleLen() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.idle)
+}
+
+//ActiveLen 返回已借出未归还的连接数
+func (m *MockPool) ActiveLen() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.borrowed)
+}
+
+//Waiters 模拟连接池达到上限时直接返回 ErrPoolExhausted 不会阻塞 总是返回0
+func (m *MockPool) Waiters() int {
+	return 0
+}
+
+//Borrowed 返回已借出未归还连接的快照
+func (m *MockPool) Borrowed() []simpleConnPool.BorrowInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	borrowed := make([]simpleConnPool.BorrowInfo, 0, len(m.borrowed))
+	for conn, at := range m.borrowed {
+		info := simpleConnPool.BorrowInfo{Age: time.Since(at)}
+		if meta, ok := conn.(simpleConnPool.WithMeta); ok {
+			info.Meta = meta.PoolMeta()
+		}
+		borrowed = append(borrowed, info)
+	}
+	return borrowed
+}
+
+//SetMaxCap 设置最多同时借出的连接数 n 小于等于0表示不限制 达到上限时获取连接返回 ErrPoolExhausted
+func (m *MockPool) SetMaxCap(n int32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxCap = n
+	return nil
+}
+
+//SetMaxIdle 设置最多保留的可复用连接数 n 为0表示不保留 未设置时不限制
+func (m *MockPool) SetMaxIdle(n int32) error {
+	if n < 0 {
+		return simpleConnPool.InvalidCapSet
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxIdle = n
+	if int32(len(m.idle)) > n {
+		m.idle = m.idle[len(m.idle)-int(n):]
+	}
+	return nil
+}
+
+//DrainIdle 丢弃所有可复用的连接 之后的获取由 factory 创建新连接 模拟连接池已关闭时返回 PoolClosed
+func (m *MockPool) DrainIdle() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.record("DrainIdle")
+	if m.closed {
+		return simpleConnPool.PoolClosed
+	}
+	m.idle = nil
+	return nil
+}
+
+//Reconfigure 检查 cfg 并应用其中的 MaxCap 与 MaxIdle
+func (m *MockPool) Reconfigure(cfg *simpleConnPool.Config) error {
+	if cfg == nil {
+		return simpleConnPool.InvalidCapSet
+	}
+	if err := cfg.Check(); err != nil {
+		return err
+	}
+	_ = m.SetMaxCap(cfg.MaxCap)
+	return m.SetMaxIdle(cfg.MaxIdle)
+}
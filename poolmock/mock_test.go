@@ -0,0 +1,116 @@
+package poolmock
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"simpleConnPool"
+)
+
+//conn 测试用连接
+type conn struct {
+	id int
+}
+
+func newMock() *MockPool {
+	id := 0
+	return New(func() any {
+		id++
+		return &conn{id: id}
+	})
+}
+
+func TestMockPoolErrorInjection(t *testing.T) {
+	m := newMock()
+	defer m.Shutdown()
+
+	errDown := errors.New("backend down")
+	m.FailGetWith(errDown)
+	if _, err := m.Get(); err != errDown {
+		t.Fatalf("Get: got %v, want injected error", err)
+	}
+	if _, err := m.GetContext(context.Background()); err != errDown {
+		t.Fatalf("GetContext: got %v, want injected error", err)
+	}
+	if err := m.Ping(context.Background()); err != errDown {
+		t.Fatalf("Ping: got %v, want injected error", err)
+	}
+	m.FailGetWith(nil)
+
+	c, err := m.Get()
+	if err != nil {
+		t.Fatalf("Get after clearing error: %v", err)
+	}
+	errPut := errors.New("put failed")
+	m.FailPutWith(errPut)
+	if err := m.Put(c); err != errPut {
+		t.Fatalf("Put: got %v, want injected error", err)
+	}
+	//Put 失败的连接仍视为未归还
+	if got := m.Outstanding(); len(got) != 1 || got[0] != c {
+		t.Fatalf("Outstanding after failed Put = %v, want [%v]", got, c)
+	}
+	m.FailPutWith(nil)
+	if err := m.Put(c); err != nil {
+		t.Fatalf("Put after clearing error: %v", err)
+	}
+
+	want := []string{"Get", "GetContext", "Ping", "Get", "Put", "Put"}
+	if got := m.Calls(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Calls = %v, want %v", got, want)
+	}
+	if n := m.CallCount("Put"); n != 2 {
+		t.Fatalf("CallCount(Put) = %d, want 2", n)
+	}
+}
+
+func TestMockPoolBorrowTracking(t *testing.T) {
+	m := newMock()
+
+	a, _ := m.Get()
+	b, _ := m.Get()
+	if n := len(m.Outstanding()); n != 2 {
+		t.Fatalf("Outstanding = %d connections, want 2", n)
+	}
+	if err := m.Put(a); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := m.Put(a); err != simpleConnPool.ErrUnknownConnection {
+		t.Fatalf("second Put: got %v, want ErrUnknownConnection", err)
+	}
+	if err := m.Put(&conn{}); err != simpleConnPool.ErrUnknownConnection {
+		t.Fatalf("Put of a foreign connection: got %v, want ErrUnknownConnection", err)
+	}
+	//归还的连接被复用
+	_, info, err := m.GetWithInfo(context.Background())
+	if err != nil || !info.FromIdle {
+		t.Fatalf("GetWithInfo = %+v, %v, want a reused connection", info, err)
+	}
+	if s := m.Stats(); s.ActiveCount != 2 || s.IdleCount != 0 || s.TotalGets != 3 {
+		t.Fatalf("Stats = %+v, want 2 active, 0 idle and 3 gets", s)
+	}
+
+	//b 未归还 DrainContext 超时
+	_ = m.Invalidate(a)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := m.DrainContext(ctx); err != simpleConnPool.ErrDrainTimeout {
+		t.Fatalf("DrainContext with a leaked connection: got %v, want ErrDrainTimeout", err)
+	}
+	if got := m.Outstanding(); len(got) != 1 || got[0] != b {
+		t.Fatalf("Outstanding = %v, want the leaked connection %v", got, b)
+	}
+	if _, err := m.Get(); err != simpleConnPool.PoolClosed {
+		t.Fatalf("Get after Shutdown: got %v, want PoolClosed", err)
+	}
+	//ShutdownContext 超时后强制移除未归还的连接
+	if err := m.ShutdownContext(ctx); err != simpleConnPool.ErrForcedShutdown {
+		t.Fatalf("ShutdownContext with a leaked connection: got %v, want ErrForcedShutdown", err)
+	}
+	if got := m.Outstanding(); len(got) != 0 {
+		t.Fatalf("Outstanding after ShutdownContext = %v, want none", got)
+	}
+}
@@ -0,0 +1,90 @@
+package simpleConnPool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+/*
+====== 多个命名连接池的注册表 =======
+*/
+
+//Registry 按名称管理多个连接池 可以并发使用 零值可以直接使用
+type Registry struct {
+	mu    sync.RWMutex
+	pools map[string]Pool
+}
+
+//NewRegistry 构造函数 返回一个空的注册表
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+//Register 以 name 注册一个连接池 name 已经被注册时返回 ErrPoolExists
+func (r *Registry) Register(name string, p Pool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.pools[name]; ok {
+		return fmt.Errorf("%w: %s", ErrPoolExists, name)
+	}
+	if r.pools == nil {
+		r.pools = make(map[string]Pool)
+	}
+	r.pools[name] = p
+	return nil
+}
+
+//Get 返回以 name 注册的连接池
+func (r *Registry) Get(name string) (Pool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.pools[name]
+	return p, ok
+}
+
+//Stats 返回所有已注册连接池的运行状态 key 为注册名称
+func (r *Registry) Stats() map[string]Stats {
+	pools := r.snapshot()
+	stats := make(map[string]Stats, len(pools))
+	for name, p := range pools {
+		stats[name] = p.Stats()
+	}
+	return stats
+}
+
+//ShutdownAll 并发地优雅关闭所有已注册的连接池 等待借出的连接归还直到 ctx 结束
+//返回所有连接池关闭错误的合并 每个错误都带有连接池名称
+func (r *Registry) ShutdownAll(ctx context.Context) error {
+	pools := r.snapshot()
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for name, p := range pools {
+		wg.Add(1)
+		go func(name string, p Pool) {
+			defer wg.Done()
+			if err := p.DrainContext(ctx); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				mu.Unlock()
+			}
+		}(name, p)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+//snapshot 返回已注册连接池的副本 避免在持锁期间调用连接池的方法
+func (r *Registry) snapshot() map[string]Pool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	pools := make(map[string]Pool, len(r.pools))
+	for name, p := range r.pools {
+		pools[name] = p
+	}
+	return pools
+}
@@ -0,0 +1,71 @@
+package simpleConnPool
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistryRegisterAndGet(t *testing.T) {
+	r := NewRegistry()
+	a, _ := NewPool(newTestConfig())
+	b, _ := NewPool(newTestConfig())
+	defer a.Shutdown()
+	defer b.Shutdown()
+
+	if err := r.Register("a", a); err != nil {
+		t.Fatalf("Register a: %v", err)
+	}
+	if err := r.Register("b", b); err != nil {
+		t.Fatalf("Register b: %v", err)
+	}
+	if err := r.Register("a", b); !errors.Is(err, ErrPoolExists) {
+		t.Fatalf("duplicate Register: got %v, want ErrPoolExists", err)
+	}
+	if p, ok := r.Get("a"); !ok || p != a {
+		t.Fatalf("Get(a) = %v, %t", p, ok)
+	}
+	if _, ok := r.Get("missing"); ok {
+		t.Fatal("Get(missing) found a pool")
+	}
+
+	conn, _ := a.Get()
+	stats := r.Stats()
+	if len(stats) != 2 || stats["a"].ActiveCount != 1 || stats["b"].ActiveCount != 0 {
+		t.Fatalf("Stats = %+v", stats)
+	}
+	_ = a.Put(conn)
+}
+
+func TestRegistryShutdownAll(t *testing.T) {
+	var r Registry
+	a, _ := NewPool(newTestConfig())
+	b, _ := NewPool(newTestConfig())
+	_ = r.Register("a", a)
+	_ = r.Register("b", b)
+
+	//b 有一个连接始终未归还 a 的连接在关闭过程中归还
+	heldA, _ := a.Get()
+	heldB, _ := b.Get()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = a.Put(heldA)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := r.ShutdownAll(ctx)
+	if !errors.Is(err, ErrDrainTimeout) || !strings.Contains(err.Error(), "b: ") || strings.Contains(err.Error(), "a: ") {
+		t.Fatalf("ShutdownAll: got %v, want only b to time out", err)
+	}
+	//并发关闭 总耗时不超过单个连接池的等待时间太多
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("ShutdownAll took %v", elapsed)
+	}
+	if !a.IsClosed() || !b.IsClosed() {
+		t.Fatal("ShutdownAll left a pool open")
+	}
+	_ = b.Put(heldB)
+}
@@ -0,0 +1,542 @@
+package simpleConnPool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+====== 分片连接池 sharded pool 的实现 =======
+*/
+
+//ShardedPool 分片连接池 将 Get/Put 分散到多个内部连接池 降低高并发下的锁竞争
+//MaxCap MaxIdle InitialCap MinIdle WaitQueue 按分片数平均拆分 借出的连接总是归还到其所属的分片
+type ShardedPool struct {
+	shards       []Pool    //内部连接池
+	next         uint32    //轮询选择分片的计数器
+	owners       connIndex //已借出连接所属的分片 key 由 KeyFunc 决定 value 为 Pool
+	idleOverflow bool      //各分片是否开启 IdleOverflow 决定 SetMaxIdle 如何拆分
+
+	eventsOnce sync.Once
+	events     chan Event //合并所有分片的事件
+}
+
+var _ Pool = (*ShardedPool)(nil)
+
+//NewShardedPool 构造一个包含 shards 个分片的连接池 MaxCap 不能小于分片数 MaxCap 小于等于0时每个分片都不限制连接数
+func NewShardedPool(poolConfig *Config, shards int) (*ShardedPool, error) {
+	if shards <= 0 || poolConfig.MaxCap > 0 && poolConfig.MaxCap < int32(shards) {
+		return nil, InvalidCapSet
+	}
+	p := &ShardedPool{shards: make([]Pool, 0, shards), owners: connIndex{keyFunc: poolConfig.KeyFunc}, idleOverflow: poolConfig.IdleOverflow}
+	for i := 0; i < shards; i++ {
+		cfg := shardConfig(poolConfig, shards, i)
+		shard, err := NewPool(&cfg)
+		if err != nil {
+			_ = p.Shutdown()
+			return nil, err
+		}
+		p.shards = append(p.shards, shard)
+	}
+	return p, nil
+}
+
+//shardConfig 返回第 i 个分片的配置 连接数相关的配置项按分片数平均拆分
+func shardConfig(poolConfig *Config, shards, i int) Config {
+	cfg := *poolConfig
+	if poolConfig.MaxCap > 0 {
+		cfg.MaxCap = splitShare(poolConfig.MaxCap, shards, i)
+	}
+	//超出 MaxCap 的突发连接数按分片拆分 各分片的 BurstCap 之和与 BurstCap 相同 分不到突发连接数的分片不启用
+	if poolConfig.BurstCap > 0 && poolConfig.MaxCap > 0 {
+		cfg.BurstCap = 0
+		if extra := splitShare(poolConfig.BurstCap-poolConfig.MaxCap, shards, i); extra > 0 {
+			cfg.BurstCap = cfg.MaxCap + extra
+		}
+	}
+	cfg.MaxIdle = idleShare(poolConfig.MaxIdle, poolConfig.IdleOverflow, shards, i)
+	cfg.InitialCap = splitShare(poolConfig.InitialCap, shards, i)
+	cfg.MinIdle = splitShare(poolConfig.MinIdle, shards, i)
+	//每个分片至少保留一个等待位置
+	if cfg.WaitQueue = splitShare(poolConfig.WaitQueue, shards, i); cfg.WaitQueue < 1 {
+		cfg.WaitQueue = 1
+	}
+	return cfg
+}
+
+//idleShare 将 maxIdle 拆分到 shards 个分片 返回第 i 个分片的份额
+//开启 IdleOverflow 时分片的 MaxIdle 不能为0 maxIdle 大于0时分不到空闲连接数的分片至少为1
+func idleShare(maxIdle int32, overflow bool, shards, i int) int32 {
+	if share := splitShare(maxIdle, shards, i); share > 0 || !overflow || maxIdle <= 0 {
+		return share
+	}
+	return 1
+}
+
+//splitShare 将 total 平均拆分到 n 个分片 返回第 i 个分片的份额 余数分给靠前的分片
+func splitShare(total int32, n, i int) int32 {
+	share := total / int32(n)
+	if int32(i) < total%int32(n) {
+		share++
+	}
+	return share
+}
+
+//Get 向连接池中获取一个连接
+func (p *ShardedPool) Get() (any, error) {
+	return p.get(context.Background(), func(shard Pool) (any, error) { return shard.Get() })
+}
+
+//GetContext 向连接池中获取一个连接 等待过程中 ctx 被取消则返回 ctx.Err()
+//调用时 ctx 已经结束则直接返回 ctx.Err() 不会从任何分片借出连接
+func (p *ShardedPool) GetContext(ctx context.Context) (any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return p.get(ctx, func(shard Pool) (any, error) { return shard.GetContext(ctx) })
+}
+
+//GetWithPriority 向连接池中获取一个连接 需要等待时在选中的分片上按 priority 排队
+func (p *ShardedPool) GetWithPriority(ctx context.Context, priority int) (any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return p.get(ctx, func(shard Pool) (any, error) { return shard.GetWithPriority(ctx, priority) })
+}
+
+//GetMany 一次获取 n 个连接 全部获取成功或全部失败 失败时已经获取的连接会被归还
+func (p *ShardedPool) GetMany(ctx context.Context, n int) ([]any, error) {
+	return getMany(p, n, func(int) (any, error) { return p.GetContext(ctx) })
+}
+
+//Use 获取一个连接并以它调用 fn fn 返回或 panic 后归还连接 fn 返回 ErrBrokenConn 时关闭连接
+func (p *ShardedPool) Use(ctx context.Context, fn func(conn any) error) error {
+	return use(p, ctx, fn)
+}
+
+//GetWithInfo 向连接池中获取一个连接 同时返回连接是否复用空闲连接 是否新创建以及等待的时间
+func (p *ShardedPool) GetWithInfo(ctx context.Context) (any, GetInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, GetInfo{Err: err}, err
+	}
+	info := GetInfo{}
+	conn, err := p.getWithInfo(ctx, &info, func(shard Pool) (any, error) {
+		conn, shardInfo, err := shard.GetWithInfo(ctx)
+		info = shardInfo
+		return conn, err
+	})
+	info.Err = err
+	return conn, info, err
+}
+
+//TryGet 向连接池中获取一个连接 所有分片都没有可用连接时立即返回 ErrPoolExhausted
+func (p *ShardedPool) TryGet() (any, error) {
+	return p.get(context.Background(), nil)
+}
+
+//GetWithTimeout 向连接池中获取一个连接 本次获取使用 d 作为最大等待时间
+//d 为 0 时使用连接池配置的 WaitTimeout d 小于 0 时与 TryGet 相同 不进行等待
+func (p *ShardedPool) GetWithTimeout(d time.Duration) (any, error) {
+	if d < 0 {
+		return p.TryGet()
+	}
+	return p.get(context.Background(), func(shard Pool) (any, error) { return shard.GetWithTimeout(d) })
+}
+
+//get 从轮询选中的分片开始依次尝试不等待地获取连接 尝试时创建连接使用 ctx
+//所有分片都没有可用连接时 wait 为空则返回 ErrPoolExhausted 否则在选中的分片上等待
+func (p *ShardedPool) get(ctx context.Context, wait func(shard Pool) (any, error)) (any, error) {
+	return p.getWithInfo(ctx, nil, wait)
+}
+
+//getWithInfo 与 get 相同 info 不为空时记录不等待获取到的连接的过程信息
+func (p *ShardedPool) getWithInfo(ctx context.Context, info *GetInfo, wait func(shard Pool) (any, error)) (any, error) {
+	n := uint32(len(p.shards))
+	start := atomic.AddUint32(&p.next, 1) % n
+	for i := uint32(0); i < n; i++ {
+		shard := p.shards[(start+i)%n]
+		conn, err := tryGet(ctx, shard, info)
+		if err == nil {
+			return p.track(shard, conn), nil
+		}
+		if err != ErrPoolExhausted {
+			return nil, err
+		}
+	}
+	if wait == nil {
+		return nil, ErrPoolExhausted
+	}
+	shard := p.shards[start]
+	conn, err := wait(shard)
+	if err != nil {
+		return nil, err
+	}
+	return p.track(shard, conn), nil
+}
+
+//tryGet 不等待地从分片获取连接 分片支持时创建连接使用 ctx 且 info 不为空时记录过程信息
+func tryGet(ctx context.Context, shard Pool, info *GetInfo) (any, error) {
+	tg, ok := shard.(interface {
+		tryGetContext(ctx context.Context, info *GetInfo) (any, error)
+	})
+	if !ok {
+		return shard.TryGet()
+	}
+	if info == nil {
+		return tg.tryGetContext(ctx, nil)
+	}
+	shardInfo := GetInfo{}
+	conn, err := tg.tryGetContext(ctx, &shardInfo)
+	if err == nil {
+		*info = shardInfo
+	}
+	return conn, err
+}
+
+//GetUncounted 在轮询选中的分片上创建一个不计入 MaxCap 的连接
+func (p *ShardedPool) GetUncounted() (any, error) {
+	shard := p.shards[atomic.AddUint32(&p.next, 1)%uint32(len(p.shards))]
+	conn, err := shard.GetUncounted()
+	if err != nil {
+		return nil, err
+	}
+	return p.track(shard, conn), nil
+}
+
+//track 记录连接所属的分片
+func (p *ShardedPool) track(shard Pool, conn any) any {
+	p.owners.Store(conn, shard)
+	return conn
+}
+
+//owner 取出并移除连接所属的分片
+func (p *ShardedPool) owner(conn any) (Pool, error) {
+	if conn == nil {
+		return nil, ConnectionIsNull
+	}
+	shard, ok := p.owners.LoadAndDelete(conn)
+	if !ok {
+		return nil, ErrUnknownConnection
+	}
+	return shard.(Pool), nil
+}
+
+//GetConn 向连接池中获取一个连接 返回连接句柄
+func (p *ShardedPool) GetConn() (*PooledConn, error) {
+	conn, err := p.Get()
+	if err != nil {
+		return nil, err
+	}
+	return newPooledConn(p, conn), nil
+}
+
+//Put 将连接归还到其所属的分片 conn 也可以是 GetConn 返回的 *PooledConn 句柄
+func (p *ShardedPool) Put(conn any) error {
+	if pc, ok := conn.(*PooledConn); ok {
+		return pc.Release()
+	}
+	shard, err := p.owner(conn)
+	if err != nil {
+		return err
+	}
+	return shard.Put(conn)
+}
+
+//CloseConn 关闭一个借出的连接 与 Invalidate 相同
+func (p *ShardedPool) CloseConn(conn any) error {
+	return p.Invalidate(conn)
+}
+
+//Close 关闭一个借出的连接
+//
+//Deprecated: 请使用 CloseConn 关闭单个连接 使用 Shutdown 关闭连接池
+func (p *ShardedPool) Close(conn any) error {
+	return p.CloseConn(conn)
+}
+
+//Invalidate 关闭一个已损坏的借出连接 并释放其所属分片的连接数
+func (p *ShardedPool) Invalidate(conn any) error {
+	shard, err := p.owner(conn)
+	if err != nil {
+		return err
+	}
+	return shard.Invalidate(conn)
+}
+
+//RefreshConn 在旧连接所属的分片上关闭旧连接并创建新连接 新连接同样属于该分片
+func (p *ShardedPool) RefreshConn(old any) (any, error) {
+	shard, err := p.owner(old)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := shard.RefreshConn(old)
+	if err != nil {
+		return nil, err
+	}
+	return p.track(shard, conn), nil
+}
+
+//Shutdown 关闭所有分片 返回第一个关闭错误
+func (p *ShardedPool) Shutdown() error {
+	var first error
+	for _, shard := range p.shards {
+		if err := shard.Shutdown(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+//DrainContext 优雅关闭所有分片 先拒绝所有分片的新请求 再等待各分片借出的连接归还
+func (p *ShardedPool) DrainContext(ctx context.Context) error {
+	_ = p.Shutdown()
+	for _, shard := range p.shards {
+		if err := shard.DrainContext(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//ShutdownContext 关闭所有分片 ctx 结束时强制关闭各分片仍未归还的连接
+//某个分片返回错误后仍会继续关闭其余分片 返回第一个错误
+func (p *ShardedPool) ShutdownContext(ctx context.Context) error {
+	_ = p.Shutdown()
+	var first error
+	for _, shard := range p.shards {
+		if err := shard.ShutdownContext(ctx); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+//WaitReady 阻塞直到所有分片初始化空闲连接完成
+func (p *ShardedPool) WaitReady(ctx context.Context) error {
+	for _, shard := range p.shards {
+		if err := shard.WaitReady(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Ping 依次检测所有分片 返回第一个错误
+func (p *ShardedPool) Ping(ctx context.Context) error {
+	for _, shard := range p.shards {
+		if err := shard.Ping(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//IsClosed 返回连接池是否已经关闭
+func (p *ShardedPool) IsClosed() bool {
+	return p.shards[0].IsClosed()
+}
+
+//Stats 返回所有分片运行状态之和 任一分片熔断时 Circuit 为 CircuitOpen
+func (p *ShardedPool) Stats() Stats {
+	return p.sumStats(Pool.Stats)
+}
+
+//StatsSnapshotAndReset 返回所有分片运行状态之和 并将各分片的累计计数器清零
+func (p *ShardedPool) StatsSnapshotAndReset() Stats {
+	return p.sumStats(Pool.StatsSnapshotAndReset)
+}
+
+//sumStats 对每个分片调用 stats 并求和
+func (p *ShardedPool) sumStats(stats func(Pool) Stats) Stats {
+	var total Stats
+	for _, shard := range p.shards {
+		s := stats(shard)
+		total.IdleCount += s.IdleCount
+		total.ActiveCount += s.ActiveCount
+		total.PeakActive += s.PeakActive
+		total.OpeningConn += s.OpeningConn
+		total.UncountedConn += s.UncountedConn
+		total.BurstConn += s.BurstConn
+		total.WaitingRequests += s.WaitingRequests
+		total.Waiters += s.Waiters
+		total.TotalGets += s.TotalGets
+		total.TotalTimeouts += s.TotalTimeouts
+		total.TotalFactoryErrors += s.TotalFactoryErrors
+		total.TotalWaits += s.TotalWaits
+		total.TotalBursts += s.TotalBursts
+		total.TotalWaitDuration += s.TotalWaitDuration
+		total.DroppedEvents += s.DroppedEvents
+		for i, n := range s.WaitLatency.Buckets {
+			total.WaitLatency.Buckets[i] += n
+		}
+		total.WaitLatency.Count += s.WaitLatency.Count
+		total.WaitLatency.Sum += s.WaitLatency.Sum
+		if s.Circuit == CircuitOpen || total.Circuit == CircuitClosed {
+			total.Circuit = s.Circuit
+		}
+	}
+	return total
+}
+
+//Events 返回合并所有分片事件的 channel 所有分片关闭后 channel 被关闭
+//消费过慢时各分片自行丢弃事件 不会阻塞 Get 与 Put
+func (p *ShardedPool) Events() <-chan Event {
+	p.eventsOnce.Do(func() {
+		p.events = make(chan Event, defaultEventBuffer)
+		var wg sync.WaitGroup
+		for _, shard := range p.shards {
+			wg.Add(1)
+			go func(events <-chan Event) {
+				defer wg.Done()
+				for e := range events {
+					p.events <- e
+				}
+			}(shard.Events())
+		}
+		go func() {
+			wg.Wait()
+			close(p.events)
+		}()
+	})
+	return p.events
+}
+
+//Len 返回当前存活的连接数
+func (p *ShardedPool) Len() int {
+	n := 0
+	for _, shard := range p.shards {
+		n += shard.Len()
+	}
+	return n
+}
+
+//IdleLen 返回当前空闲连接数
+func (p *ShardedPool) IdleLen() int {
+	n := 0
+	for _, shard := range p.shards {
+		n += shard.IdleLen()
+	}
+	return n
+}
+
+//ActiveLen 返回当前已借出未归还的连接数
+func (p *ShardedPool) ActiveLen() int {
+	n := 0
+	for _, shard := range p.shards {
+		n += shard.ActiveLen()
+	}
+	return n
+}
+
+//ResetPeak 重置所有分片的 PeakActive
+func (p *ShardedPool) ResetPeak() {
+	for _, shard := range p.shards {
+		shard.ResetPeak()
+	}
+}
+
+//Waiters 返回所有分片中阻塞在等待队列中的 goroutine 数之和
+func (p *ShardedPool) Waiters() int {
+	n := 0
+	for _, shard := range p.shards {
+		n += shard.Waiters()
+	}
+	return n
+}
+
+//Borrowed 返回所有分片中已借出未归还连接的快照
+func (p *ShardedPool) Borrowed() []BorrowInfo {
+	var borrowed []BorrowInfo
+	for _, shard := range p.shards {
+		borrowed = append(borrowed, shard.Borrowed()...)
+	}
+	return borrowed
+}
+
+//SetMaxCap 将最大并发存活连接数 n 平均拆分到各分片 n 不能小于分片数 n 小于等于0表示所有分片都不限制
+//先检查所有分片 任一分片不接受拆分后的值时返回错误且不修改任何分片
+func (p *ShardedPool) SetMaxCap(n int32) error {
+	if n > 0 && n < int32(len(p.shards)) {
+		return InvalidCapSet
+	}
+	shares := make([]int32, len(p.shards))
+	for i, shard := range p.shards {
+		shares[i] = n
+		if n > 0 {
+			shares[i] = splitShare(n, len(p.shards), i)
+		}
+		if cc, ok := shard.(interface{ checkMaxCap(int32) error }); ok {
+			if err := cc.checkMaxCap(shares[i]); err != nil {
+				return err
+			}
+		}
+	}
+	var first error
+	for i, shard := range p.shards {
+		if err := shard.SetMaxCap(shares[i]); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+//Reconfigure 按分片拆分 cfg 后修改每个分片的配置 MaxCap 不能小于分片数
+//所有分片的配置都合法时才会修改 任一分片的配置不合法时返回错误且不修改任何分片
+func (p *ShardedPool) Reconfigure(cfg *Config) error {
+	if cfg == nil || cfg.MaxCap > 0 && cfg.MaxCap < int32(len(p.shards)) {
+		return InvalidCapSet
+	}
+	cfgs := make([]Config, len(p.shards))
+	for i, shard := range p.shards {
+		cfgs[i] = shardConfig(cfg, len(p.shards), i)
+		check := cfgs[i].Check
+		if cr, ok := shard.(interface{ checkReconfigure(*Config) error }); ok {
+			check = func() error { return cr.checkReconfigure(&cfgs[i]) }
+		}
+		if err := check(); err != nil {
+			return err
+		}
+	}
+	var first error
+	for i, shard := range p.shards {
+		if err := shard.Reconfigure(&cfgs[i]); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+//SetMaxIdle 将最大空闲连接数 n 平均拆分到各分片
+//先检查所有分片 任一分片不接受拆分后的值时返回错误且不修改任何分片
+func (p *ShardedPool) SetMaxIdle(n int32) error {
+	if n < 0 {
+		return InvalidCapSet
+	}
+	for i, shard := range p.shards {
+		if cc, ok := shard.(interface{ checkMaxIdle(int32) error }); ok {
+			if err := cc.checkMaxIdle(idleShare(n, p.idleOverflow, len(p.shards), i)); err != nil {
+				return err
+			}
+		}
+	}
+	var first error
+	for i, shard := range p.shards {
+		if err := shard.SetMaxIdle(idleShare(n, p.idleOverflow, len(p.shards), i)); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+//DrainIdle 关闭所有分片的空闲连接 返回第一个错误
+func (p *ShardedPool) DrainIdle() error {
+	var first error
+	for _, shard := range p.shards {
+		if err := shard.DrainIdle(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
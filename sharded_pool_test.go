@@ -0,0 +1,306 @@
+package simpleConnPool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShardedPool(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 4
+	cfg.MaxIdle = 4
+	p, err := NewShardedPool(cfg, 4)
+	if err != nil {
+		t.Fatalf("NewShardedPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	//每个分片最多一个连接 借出四个连接后所有分片耗尽
+	conns := make([]interface{}, 4)
+	for i := range conns {
+		if conns[i], err = p.TryGet(); err != nil {
+			t.Fatalf("TryGet %d: %v", i, err)
+		}
+	}
+	if _, err := p.TryGet(); err != ErrPoolExhausted {
+		t.Fatalf("TryGet on exhausted pool: got %v, want ErrPoolExhausted", err)
+	}
+	if got := p.Stats().ActiveCount; got != 4 {
+		t.Fatalf("ActiveCount = %d, want 4", got)
+	}
+
+	//连接归还到各自的分片 每个分片重新拥有一个空闲连接
+	for _, conn := range conns {
+		if err := p.Put(conn); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	for i, shard := range p.shards {
+		if got := shard.IdleLen(); got != 1 {
+			t.Fatalf("shard %d IdleLen = %d, want 1", i, got)
+		}
+	}
+	if err := p.Put(conns[0]); err != ErrUnknownConnection {
+		t.Fatalf("double Put: got %v, want ErrUnknownConnection", err)
+	}
+}
+
+func TestShardedGetCancelledContext(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.InitialCap = 2
+	p, err := NewShardedPool(cfg, 2)
+	if err != nil {
+		t.Fatalf("NewShardedPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	//每个分片都有空闲连接 ctx 已经结束时仍然不借出
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.GetContext(ctx); err != context.Canceled {
+		t.Fatalf("GetContext: got %v, want context.Canceled", err)
+	}
+	if _, err := p.GetWithPriority(ctx, 1); err != context.Canceled {
+		t.Fatalf("GetWithPriority: got %v, want context.Canceled", err)
+	}
+	if _, info, err := p.GetWithInfo(ctx); err != context.Canceled || info.Err != err {
+		t.Fatalf("GetWithInfo: got %v (info.Err %v), want context.Canceled", err, info.Err)
+	}
+	if s := p.Stats(); s.ActiveCount != 0 || s.TotalGets != 0 {
+		t.Fatalf("ActiveCount/TotalGets = %d/%d, want 0/0", s.ActiveCount, s.TotalGets)
+	}
+}
+
+func TestShardedGetContextFactoryDeadline(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Factory = nil
+	cfg.FactoryContext = func(ctx context.Context) (interface{}, error) {
+		select {
+		case <-time.After(time.Second):
+			return &testConn{}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	p, err := NewShardedPool(cfg, 2)
+	if err != nil {
+		t.Fatalf("NewShardedPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := p.GetContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("GetContext: got %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("GetContext returned after %v, want FactoryContext to see the deadline", elapsed)
+	}
+}
+
+func TestShardedSetMaxCapAllOrNothing(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 8
+	cfg.MaxIdle = 4
+	p, err := NewShardedPool(cfg, 2)
+	if err != nil {
+		t.Fatalf("NewShardedPool: %v", err)
+	}
+	defer p.Shutdown()
+	//拆分为 2 和 1 第二个分片的 MaxIdle 为2 不接受1
+	if err := p.SetMaxCap(3); err != InvalidCapSet {
+		t.Fatalf("SetMaxCap(3): got %v, want InvalidCapSet", err)
+	}
+	for i, shard := range p.shards {
+		if got := atomic.LoadInt32(&shard.(*connectionPool).maxActiveConn); got != 4 {
+			t.Fatalf("shard %d MaxCap = %d, want 4", i, got)
+		}
+	}
+}
+
+func TestShardedSetMaxIdleAllOrNothing(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 3
+	cfg.MaxIdle = 2
+	p, err := NewShardedPool(cfg, 2)
+	if err != nil {
+		t.Fatalf("NewShardedPool: %v", err)
+	}
+	defer p.Shutdown()
+	//拆分为 2 和 2 第二个分片的 MaxCap 为1 不接受2
+	if err := p.SetMaxIdle(4); err != InvalidCapSet {
+		t.Fatalf("SetMaxIdle(4): got %v, want InvalidCapSet", err)
+	}
+	for i, shard := range p.shards {
+		if got := shard.(*connectionPool).maxIdle; got != 1 {
+			t.Fatalf("shard %d MaxIdle = %d, want 1", i, got)
+		}
+	}
+}
+
+func TestShardedReconfigureAllOrNothing(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 4
+	cfg.MaxIdle = 2
+	cfg.IdleOverflow = true
+	p, err := NewShardedPool(cfg, 2)
+	if err != nil {
+		t.Fatalf("NewShardedPool: %v", err)
+	}
+	defer p.Shutdown()
+	//每个分片的配置本身合法 但分片创建时开启了 IdleOverflow MaxIdle 不能为0
+	next := *cfg
+	next.IdleOverflow = false
+	next.MaxIdle = 0
+	next.MaxCap = 8
+	if err := p.Reconfigure(&next); err != InvalidCapSet {
+		t.Fatalf("Reconfigure: got %v, want InvalidCapSet", err)
+	}
+	for i, shard := range p.shards {
+		if got := atomic.LoadInt32(&shard.(*connectionPool).maxActiveConn); got != 2 {
+			t.Fatalf("shard %d MaxCap = %d, want 2", i, got)
+		}
+	}
+}
+
+func TestShardedIdleOverflowSmallMaxIdle(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxCap = 4
+	cfg.MaxIdle = 1
+	cfg.IdleOverflow = true
+	p, err := NewShardedPool(cfg, 2)
+	if err != nil {
+		t.Fatalf("NewShardedPool(MaxIdle 1, IdleOverflow, 2 shards): %v", err)
+	}
+	defer p.Shutdown()
+	if err := p.SetMaxIdle(1); err != nil {
+		t.Fatalf("SetMaxIdle(1): %v", err)
+	}
+	//分不到空闲连接数的分片至少为1 仍可以缓存空闲连接
+	for i, shard := range p.shards {
+		if got := shard.(*connectionPool).maxIdle; got != 1 {
+			t.Fatalf("shard %d MaxIdle = %d, want 1", i, got)
+		}
+	}
+	if err := p.SetMaxIdle(0); err != InvalidCapSet {
+		t.Fatalf("SetMaxIdle(0): got %v, want InvalidCapSet", err)
+	}
+}
+
+func TestShardedBurstCap(t *testing.T) {
+	for _, tc := range []struct {
+		maxCap, burstCap int32
+		shards           int
+	}{
+		{4, 6, 2},
+		{5, 6, 3},
+	} {
+		cfg := newTestConfig()
+		cfg.MaxCap = tc.maxCap
+		cfg.MaxIdle = tc.maxCap
+		cfg.BurstCap = tc.burstCap
+		p, err := NewShardedPool(cfg, tc.shards)
+		if err != nil {
+			t.Fatalf("NewShardedPool(MaxCap %d, BurstCap %d, %d shards): %v", tc.maxCap, tc.burstCap, tc.shards, err)
+		}
+		//所有分片合计最多 BurstCap 个连接
+		var n int32
+		for ; n <= tc.burstCap; n++ {
+			if _, err := p.TryGet(); err != nil {
+				if err != ErrPoolExhausted {
+					t.Fatalf("TryGet: %v", err)
+				}
+				break
+			}
+		}
+		if n != tc.burstCap {
+			t.Fatalf("MaxCap %d, BurstCap %d, %d shards: opened %d connections, want %d", tc.maxCap, tc.burstCap, tc.shards, n, tc.burstCap)
+		}
+		if s := p.Stats(); s.BurstConn != tc.burstCap-tc.maxCap {
+			t.Fatalf("BurstConn = %d, want %d", s.BurstConn, tc.burstCap-tc.maxCap)
+		}
+		_ = p.Shutdown()
+	}
+}
+
+func TestNewShardedPoolInvalidCap(t *testing.T) {
+	cfg := newTestConfig()
+	if _, err := NewShardedPool(cfg, 0); err != InvalidCapSet {
+		t.Fatalf("0 shards: got %v, want InvalidCapSet", err)
+	}
+	if _, err := NewShardedPool(cfg, int(cfg.MaxCap)+1); err != InvalidCapSet {
+		t.Fatalf("more shards than MaxCap: got %v, want InvalidCapSet", err)
+	}
+}
+
+func TestShardedPoolConcurrent(t *testing.T) {
+	cfg := newTestConfig()
+	p, err := NewShardedPool(cfg, 4)
+	if err != nil {
+		t.Fatalf("NewShardedPool: %v", err)
+	}
+	defer p.Shutdown()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				conn, err := p.Get()
+				if err != nil {
+					t.Errorf("Get: %v", err)
+					return
+				}
+				if err := p.Put(conn); err != nil {
+					t.Errorf("Put: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if got := p.ActiveLen(); got != 0 {
+		t.Fatalf("ActiveLen = %d, want 0", got)
+	}
+	if got := p.Len(); got > int(cfg.MaxCap) {
+		t.Fatalf("Len = %d, exceeds MaxCap %d", got, cfg.MaxCap)
+	}
+}
+
+func BenchmarkShardedGetPut(b *testing.B) {
+	newSingle := func(cfg *Config) (Pool, error) { return NewPool(cfg) }
+	newSharded := func(cfg *Config) (Pool, error) { return NewShardedPool(cfg, 8) }
+	for _, bc := range []struct {
+		name    string
+		newPool func(*Config) (Pool, error)
+	}{{"Single", newSingle}, {"Sharded", newSharded}} {
+		b.Run(bc.name, func(b *testing.B) {
+			cfg := newTestConfig()
+			cfg.MaxCap = 64
+			cfg.MaxIdle = 64
+			cfg.WaitQueue = 128
+			p, err := bc.newPool(cfg)
+			if err != nil {
+				b.Fatalf("new pool: %v", err)
+			}
+			defer p.Shutdown()
+			//至少 64 个并发协程
+			b.SetParallelism(64)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					conn, err := p.Get()
+					if err != nil {
+						b.Errorf("Get: %v", err)
+						return
+					}
+					_ = p.Put(conn)
+				}
+			})
+		})
+	}
+}
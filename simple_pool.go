@@ -1,6 +1,17 @@
 package simpleConnPool
 
 import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -11,163 +22,2036 @@ import (
 
 // Config 连接池相关配置
 type Config struct {
-	InitialCap  int32                       //连接池中拥有的最小连接数
-	MaxCap      int32                       //最大并发存活连接数
-	MaxIdle     int32                       //最大空闲连接
-	Factory     func() (interface{}, error) //生成连接的方法
-	Close       func(interface{}) error     //关闭连接的方法
-	IdleTimeout time.Duration               //连接最大空闲时间，超过该事件则将失效
-	WaitTimeout time.Duration               //获取链接最大可用时间
-	WaitQueue   int32                       //最大等待请求获取链接数量
+	InitialCap        int32                                          //连接池中拥有的最小连接数 空闲超时 存活检测与最大存活时间回收后由后台维护协程补足
+	MaxCap            int32                                          //最大并发存活连接数 小于等于0表示不限制 Get 总是创建新连接而不会等待
+	BurstCap          int32                                          //大于 MaxCap 时允许短时突发 存活连接数达到 MaxCap 后 Get 不再等待 而是继续创建突发连接直到 BurstCap 突发连接归还时直接关闭 不会放入空闲队列 小于等于0表示不启用
+	MaxIdle           int32                                          //最大空闲连接 为0表示不缓存空闲连接 没有等待请求时归还的连接直接关闭
+	NoIdle            bool                                           //为 true 时不复用连接 每次 Get 创建新连接 每次 Put 关闭连接 仍受 MaxCap 与等待队列限制 MaxIdle InitialCap MinIdle 必须为0 用于排查问题是否出在连接复用上
+	IdleOverflow      bool                                           //为 true 时空闲队列已满也保留归还的连接 最多保存 MaxCap 个 MaxIdle 只作为回收目标且必须大于0 超出部分中空闲超过 MaintainInterval 的连接由后台维护协程关闭 避免突发流量下反复关闭与创建连接
+	Factory           func() (interface{}, error)                    //生成连接的方法
+	FactoryContext    func(ctx context.Context) (interface{}, error) //生成连接的方法 ctx 为 GetContext 传入的上下文 设置后优先于 Factory 使用
+	Close             func(interface{}) error                        //关闭连接的方法 为空时对实现了 io.Closer 的连接调用其 Close 未实现时关闭返回 InvalidCloseSet
+	IdleTimeout       time.Duration                                  //连接最大空闲时间，超过该事件则将失效
+	IdleTimeoutJitter time.Duration                                  //每个连接的实际最大空闲时间在 IdleTimeout 上下随机浮动的范围 避免同时空闲的连接同时失效 必须小于 IdleTimeout
+	WaitTimeout       time.Duration                                  //获取链接最大等待时间 小于等于0表示一直等待 直到获取到连接或 ctx 结束
+	WaitQueue         int32                                          //最大等待请求获取链接数量 等待队列已满时按 OnQueueFull 处理
+	OnQueueFull       QueueFullPolicy                                //等待队列已满时的处理方式 默认 QueueFullReject
+	MaxLifetime       time.Duration                                  //连接最大存活时间，超过该时间则将被回收 小于等于0表示不限制
+	MaxUsage          int32                                          //连接最多被借出的次数 达到后归还时将被关闭 小于等于0表示不限制
+	MinIdle           int32                                          //后台维护协程保持的最少空闲连接数
+	PinInitial        bool                                           //为 true 时 InitialCap 个连接被固定 不受 IdleTimeout MaxLifetime MaxUsage 回收 也不会被空闲队列挤出 存活检测失败或被关闭后由后台维护协程创建新的固定连接补足
+	Strategy          Strategy                                       //空闲连接的借出顺序 默认 FIFO
+	FairGetMany       bool                                           //为 true 时 GetMany 每获取一个连接后让出 有其他请求在等待时排在它们之后获取下一个连接 避免批量获取占满连接数
+
+	MaintainInterval time.Duration //后台维护协程的运行间隔 默认为 IdleTimeout/2 未设置 IdleTimeout 时为 1s
+
+	WarmupAsync   bool          //为 true 时 NewPool 立即返回 在后台协程中创建 InitialCap 个连接 创建失败只记录日志
+	WarmupTimeout time.Duration //同步初始化 InitialCap 个连接的最长时间 到期后 NewPool 使用已经创建的连接返回 不等待正在进行的创建 到期后才创建成功的连接会被关闭 FactoryContext 会收到到期的 ctx 小于等于0表示不限制
+
+	Validate            func(interface{}) error //借出空闲连接前的检测方法 返回错误则关闭该连接 为空表示不检测
+	ValidateOnPut       bool                    //为 true 时 Put 也对归还的连接执行 Validate 未设置 Validate 时使用 HealthCheck 检测失败则关闭连接而不放回
+	Reset               func(interface{}) error //归还的连接重新放入空闲队列或交给等待请求前执行的重置方法 例如回滚事务 返回错误则关闭该连接 为空表示不重置
+	MaxValidateFailures int32                   //单次获取连接中 Validate 失败的最多次数 达到后返回 ErrNoHealthyConnections 小于等于0表示不限制
+
+	HealthCheck         func(interface{}) error //后台维护协程定期对空闲连接执行的存活检测 返回错误则关闭该连接 为空表示不检测
+	HealthCheckInterval time.Duration           //存活检测的间隔 默认与 MaintainInterval 相同
+
+	Logger              Logger //日志 为空时不输出日志
+	EventBuffer         int32  //Events 返回的事件 channel 的缓冲大小 默认为 128
+	ShutdownConcurrency int    //Shutdown 与 DrainContext 并发关闭空闲连接的协程数 默认为 GOMAXPROCS
+
+	KeyFunc func(interface{}) interface{} //从连接得到用于跟踪借出连接的可比较 key 为空时可比较的连接直接作为 key 不可比较的连接按接口中的数据指针区分
+
+	clock clock //时间源 仅供包内测试注入 为空时使用 realClock
+
+	optionsSet optionMask //通过 With* 设置过的配置项 仅供 newConfig 补全默认值
+
+	FactoryRetries      int           //Get 中创建连接失败后的重试次数 默认不重试
+	FactoryRetryBackoff time.Duration //第一次重试前的等待时间 之后每次重试翻倍
+	CreateTimeout       time.Duration //每次调用 Factory 或 FactoryContext 创建连接的最长时间 与 WaitTimeout 分别计算 超时返回 ErrCreateTimeout 并释放占用的连接数 小于等于0表示不限制
+
+	FailureThreshold int32         //连续创建连接失败达到该次数后熔断 小于等于0表示不启用熔断
+	FailureWindow    time.Duration //统计连续失败的时间窗口 超过窗口的失败重新计数 小于等于0表示不限制
+	CircuitCooldown  time.Duration //熔断持续时间 之后放行一个试探连接 默认 5s
+
+	LeakThreshold time.Duration //连接借出超过该时间未归还则输出泄漏警告 小于等于0表示不检测
+	LeakStack     bool          //是否记录借出连接时的调用栈 用于泄漏警告
+
+	OnGet   func(conn interface{})            //连接被借出后调用
+	OnPut   func(conn interface{})            //连接被归还时调用
+	OnClose func(conn interface{}, err error) //连接被关闭后调用 err 为关闭方法的返回值 关闭出错时连接同样视为已关闭
+}
+
+//Strategy 空闲连接的借出顺序
+type Strategy int32
+
+const (
+	FIFO Strategy = iota //优先借出最早归还的连接 流量均匀分布到所有连接
+	LIFO                 //优先借出最近归还的连接 少量热连接承担流量 其余连接空闲超时后被回收
+)
+
+//QueueFullPolicy 等待队列已满时的处理方式
+type QueueFullPolicy int32
+
+const (
+	QueueFullReject  QueueFullPolicy = iota //立即返回 ErrWaitQueueFull
+	QueueFullBlock                          //一直等待等待队列出现空位 直到 ctx 结束或连接池关闭 占用空位后再按 WaitTimeout 等待连接
+	QueueFullTimeout                        //最多等待 WaitTimeout 仍没有空位则返回 ErrWaitQueueFull
+)
+
+//String 返回借出顺序名称
+func (s Strategy) String() string {
+	if s == LIFO {
+		return "LIFO"
+	}
+	return "FIFO"
 }
 
 //channelPool 连接池 存放连接信息
 type connectionPool struct {
-	idleQueue   chan *idleConn      //空闲连接队列
-	factory     func() (any, error) //连接创建函数
-	close       func(any) error     //链接对应的关闭函数
-	reqQueue    chan connReq        //请求等待队列
-	idleTimeOut time.Duration       //空闲连接超时时间
-	waitTimeOut time.Duration       //请求等待连接时间
+	counters poolCounters //累计计数器
+
+	validate            func(any) error  //借出空闲连接前的检测函数
+	validateOnPut       bool             //归还连接时是否执行检测
+	reset               func(any) error  //归还连接时的重置函数
+	maxValidateFailures int32            //单次获取连接中 Validate 失败的最多次数
+	fairGetMany         bool             //GetMany 是否在每获取一个连接后让出
+	noIdle              bool             //是否不复用连接 归还的连接总是被关闭
+	idleOverflow        bool             //空闲队列是否可以超出 maxIdle 保存至多 maxActiveConn 个连接
+	healthCheck         func(any) error  //空闲连接的存活检测函数
+	onQueueFull         QueueFullPolicy  //等待队列已满时的处理方式
+	maxLifetime         time.Duration    //连接最大存活时间
+	maxUsage            int32            //连接最多被借出的次数
+	minIdle             int32            //后台维护协程保持的最少空闲连接数
+	initialCap          int32            //后台维护协程保持的最少存活连接数
+	pinInitial          bool             //是否固定 initialCap 个连接
+	pinnedConn          int32            //当前存活的固定连接数 仅通过原子操作读写
+	idleFloor           int32            //后台回收时保留的最少空闲连接数
+	logger              Logger           //日志
+	factoryRetries      int              //创建连接失败后的重试次数
+	factoryRetryBackoff time.Duration    //第一次重试前的等待时间
+	createTimeout       time.Duration    //每次创建连接的最长时间
+	breaker             *circuitBreaker  //连接创建熔断器 为空表示不启用
+	leakThreshold       time.Duration    //连接泄漏检测阈值
+	leakStack           bool             //是否记录借出连接时的调用栈
+	onGet               func(any)        //连接被借出后的回调
+	onPut               func(any)        //连接被归还时的回调
+	onClose             func(any, error) //连接被关闭后的回调
 
 	maxActiveConn int32 //允许的最大运行的连接数
+	burstCap      int32 //包含突发连接在内的最大连接数 小于等于0表示不启用
 	openingConn   int32 //当前正在运行的连接数
+	uncountedConn int32 //当前借出的不计入最大连接数的连接数
+	burstConn     int32 //当前存活的突发连接数 包含在 openingConn 中
+	activeConn    int32 //当前已借出未归还的连接数
+	maxActiveSeen int32 //activeConn 达到过的最大值 ResetPeak 时重新开始统计
+	waiting       int32 //当前阻塞在等待队列中的请求数 加入队列时加一 wait 返回时减一
+
+	settingsMu  sync.RWMutex  //保护 settings
+	settings    *poolSettings //可以通过 Reconfigure 修改的配置 修改时整体替换 不会原地修改
+	maintaining int32         //后台维护协程是否已经启动 1 表示已启动
+
+	closed int32         //连接池是否已经关闭 1 表示已关闭
+	done   chan struct{} //连接池关闭时被关闭 用于唤醒所有阻塞中的请求
+	ready  chan struct{} //初始化空闲连接完成时被关闭
+
+	idleMu  sync.Mutex  //保护 idle maxIdle
+	idle    []*idleConn //空闲连接队列 队头为最早放入的连接
+	maxIdle int32       //最大空闲连接数
+	lifo    bool        //是否优先借出最近归还的连接
+
+	eventsMu            sync.RWMutex //保护 events eventsClosed
+	events              chan Event   //事件 channel 第一次调用 Events 时创建
+	eventsClosed        bool         //事件 channel 是否已经关闭
+	eventsOn            int32        //是否发布事件 1 表示已调用 Events 且未关闭
+	eventBuffer         int32        //事件 channel 的缓冲大小
+	shutdownConcurrency int          //并发关闭空闲连接的协程数
+	clock               clock        //时间源
+
+	waitMu    sync.Mutex    //保护 waiters 加锁顺序为 waitMu 之后 idleMu
+	waiters   *list.List    //等待获取连接的请求队列 元素为 *connReq 按优先级从高到低 相同优先级队头为最早等待的请求
+	waitSlots chan struct{} //等待队列的空位 请求加入等待队列前占用一个 离开时释放
+
+	borrowedConns connIndex //已借出的连接 key 由 KeyFunc 决定 value 为 *borrowRecord 归还或关闭时删除
+}
+
+//poolSettings 可以通过 Reconfigure 修改的配置
+type poolSettings struct {
+	factory           func(context.Context) (any, error) //连接创建函数
+	close             func(any) error                    //链接对应的关闭函数
+	idleTimeOut       time.Duration                      //空闲连接超时时间
+	idleTimeoutJitter time.Duration                      //空闲连接超时时间的随机浮动范围
+	waitTimeOut       time.Duration                      //请求等待连接时间
+}
+
+//newPoolSettings 从配置中取出可以通过 Reconfigure 修改的部分
+func newPoolSettings(poolConfig *Config) *poolSettings {
+	s := &poolSettings{
+		factory:           poolConfig.FactoryContext,
+		close:             poolConfig.Close,
+		idleTimeOut:       poolConfig.IdleTimeout,
+		idleTimeoutJitter: poolConfig.IdleTimeoutJitter,
+		waitTimeOut:       poolConfig.WaitTimeout,
+	}
+	if s.factory == nil {
+		factory := poolConfig.Factory
+		s.factory = func(context.Context) (any, error) { return factory() }
+	}
+	if s.close == nil {
+		s.close = closeCloser
+	}
+	return s
+}
+
+//closeCloser 未设置 Close 时使用的关闭方法 连接实现了 io.Closer 时调用其 Close 否则返回 InvalidCloseSet
+func closeCloser(conn any) error {
+	if closer, ok := conn.(io.Closer); ok {
+		return closer.Close()
+	}
+	return InvalidCloseSet
 }
 
+//idleConn 连接包装 记录连接的状态信息 在空闲队列与借出期间保持不变
 type idleConn struct {
 	connection     any
-	lastActiveTime time.Time
+	close          func(any) error //创建连接时生效的关闭方法 Reconfigure 修改 Close 后已有连接仍使用创建时的方法
+	createdAt      time.Time       //连接创建时间
+	idleDeadline   time.Time       //空闲超过该时间后失效 未设置 IdleTimeout 时为零值
+	lastActiveTime time.Time       //最近一次变为空闲的时间 空闲队列超出上限时优先关闭最早的连接
+	usage          int32           //累计被借出的次数
+	uncounted      bool            //是否为 GetUncounted 创建的连接 不占用连接数 归还时直接关闭
+	pinned         bool            //是否为 PinInitial 固定的连接 不会被回收 创建后不再修改
+	burst          bool            //是否为超出 MaxCap 创建的突发连接 归还时直接关闭 创建后不再修改
+}
+
+//borrowRecord 一次借出的记录 每次借出新建 借出期间除 reported 外不再修改
+//泄漏检测读取的是本次借出的记录 连接归还后再次借出不会与其产生数据竞争
+type borrowRecord struct {
+	idleC    *idleConn
+	at       time.Time //借出时间
+	stack    []byte    //借出时的调用栈 仅在开启 LeakStack 时记录
+	reported int32     //是否已经输出过泄漏警告 1 表示已输出
 }
 
+//connReqPool 复用 connReq 及其 channel 减少等待路径上的内存分配
+var connReqPool = sync.Pool{
+	New: func() any {
+		return &connReq{idleConn: make(chan *idleConn, 1)}
+	},
+}
+
+//connReq 等待获取连接的请求
 type connReq struct {
-	abandon  bool     //此请求是否被抛弃
-	idleConn chan any //一个空闲连接
+	idleConn chan *idleConn //交给该请求的连接 缓冲为1 由 waitMu 保护的出队方发送
+	elem     *list.Element  //在 waiters 中的位置 出队后为 nil 由 waitMu 保护
+	priority int            //优先级 越大越先获得连接
+	err      error          //为该请求创建连接失败时的错误 与 nil 连接一起交给请求 发送前写入
 }
 
-//NewPool 构造函数 返回一个pool
+//NewPool 构造函数 返回一个pool 配置不合法时返回 Config.Check 的组合错误
 func NewPool(poolConfig *Config) (Pool, error) {
-	if !(poolConfig.InitialCap <= poolConfig.MaxIdle && poolConfig.MaxCap >= poolConfig.MaxIdle && poolConfig.InitialCap >= 0) {
-		return nil, InvalidCapSet
-	}
-	if poolConfig.Factory == nil {
-		return nil, InvalidFactorySet
-	}
-	if poolConfig.Close == nil {
-		return nil, InvalidCloseSet
+	return NewPoolContext(context.Background(), poolConfig)
+}
+
+//NewPoolContext 构造函数 同步初始化空闲连接时将 ctx 传给 FactoryContext
+//初始化期间 ctx 结束则关闭已经创建的连接并返回 ctx.Err() WarmupAsync 的后台初始化不受 ctx 影响
+func NewPoolContext(ctx context.Context, poolConfig *Config) (Pool, error) {
+	if err := poolConfig.Check(); err != nil {
+		return nil, err
 	}
 
 	c := &connectionPool{
-		idleQueue:     make(chan *idleConn, poolConfig.MaxIdle),
-		factory:       poolConfig.Factory,
-		close:         poolConfig.Close,
-		reqQueue:      make(chan connReq, poolConfig.WaitQueue),
-		idleTimeOut:   poolConfig.IdleTimeout,
-		waitTimeOut:   poolConfig.WaitTimeout,
-		maxActiveConn: poolConfig.MaxCap,
-		openingConn:   poolConfig.InitialCap,
+		idle:                make([]*idleConn, 0, poolConfig.MaxIdle),
+		maxIdle:             poolConfig.MaxIdle,
+		lifo:                poolConfig.Strategy == LIFO,
+		validate:            poolConfig.Validate,
+		validateOnPut:       poolConfig.ValidateOnPut,
+		reset:               poolConfig.Reset,
+		maxValidateFailures: poolConfig.MaxValidateFailures,
+		fairGetMany:         poolConfig.FairGetMany,
+		noIdle:              poolConfig.NoIdle,
+		burstCap:            poolConfig.BurstCap,
+		idleOverflow:        poolConfig.IdleOverflow,
+		healthCheck:         poolConfig.HealthCheck,
+		waiters:             list.New(),
+		borrowedConns:       connIndex{keyFunc: poolConfig.KeyFunc},
+		waitSlots:           make(chan struct{}, poolConfig.WaitQueue),
+		settings:            newPoolSettings(poolConfig),
+		onQueueFull:         poolConfig.OnQueueFull,
+		maxLifetime:         poolConfig.MaxLifetime,
+		maxUsage:            poolConfig.MaxUsage,
+		minIdle:             poolConfig.MinIdle,
+		initialCap:          poolConfig.InitialCap,
+		pinInitial:          poolConfig.PinInitial,
+		idleFloor:           poolConfig.InitialCap,
+		logger:              poolConfig.Logger,
+		factoryRetries:      poolConfig.FactoryRetries,
+		factoryRetryBackoff: poolConfig.FactoryRetryBackoff,
+		createTimeout:       poolConfig.CreateTimeout,
+		leakThreshold:       poolConfig.LeakThreshold,
+		leakStack:           poolConfig.LeakStack,
+		onGet:               poolConfig.OnGet,
+		onPut:               poolConfig.OnPut,
+		onClose:             poolConfig.OnClose,
+		maxActiveConn:       poolConfig.MaxCap,
+		done:                make(chan struct{}),
+		ready:               make(chan struct{}),
+	}
+	if c.logger == nil {
+		c.logger = nopLogger{}
+	}
+	if c.clock = poolConfig.clock; c.clock == nil {
+		c.clock = realClock{}
 	}
+	if c.shutdownConcurrency = poolConfig.ShutdownConcurrency; c.shutdownConcurrency <= 0 {
+		c.shutdownConcurrency = runtime.GOMAXPROCS(0)
+	}
+	if c.eventBuffer = poolConfig.EventBuffer; c.eventBuffer <= 0 {
+		c.eventBuffer = defaultEventBuffer
+	}
+	c.breaker = newCircuitBreaker(poolConfig.FailureThreshold, poolConfig.FailureWindow, poolConfig.CircuitCooldown, c.logger, c.clock)
 	//初始化空闲连接
-	for i := int32(0); i < poolConfig.InitialCap; i++ {
-		conn, err := c.factory()
-		if err != nil {
-			return nil, InitPoolErr
+	if poolConfig.WarmupAsync {
+		go c.warmup(poolConfig.InitialCap)
+	} else {
+		s := c.loadSettings()
+		//WarmupTimeout 到期后停止初始化 使用已经创建的连接 ctx 本身结束时仍按初始化失败处理
+		warmCtx := ctx
+		if poolConfig.WarmupTimeout > 0 {
+			var cancel context.CancelFunc
+			warmCtx, cancel = context.WithTimeout(ctx, poolConfig.WarmupTimeout)
+			defer cancel()
+		}
+		warmupExpired := func() bool {
+			if ctx.Err() != nil || warmCtx.Err() == nil {
+				return false
+			}
+			c.logger.Warnf("simpleConnPool: init pool: warmup timed out after %v with %d of %d connections", poolConfig.WarmupTimeout, len(c.idle), poolConfig.InitialCap)
+			return true
 		}
-		c.idleQueue <- &idleConn{
-			connection:     conn,
-			lastActiveTime: time.Now(),
+		for i := int32(0); i < poolConfig.InitialCap; i++ {
+			if warmupExpired() {
+				break
+			}
+			//不等待阻塞的 Factory 到期后才创建成功的连接会被关闭
+			conn, err := c.factoryWithin(warmCtx, s)
+			//Factory 或 FactoryContext 因 WarmupTimeout 到期而失败时同样结束初始化
+			if err != nil && warmupExpired() {
+				break
+			}
+			if err == nil && ctx.Err() != nil {
+				//ctx 结束后才创建成功的连接同样需要关闭
+				_ = c.closeRaw(c.newIdleConn(conn, s.close))
+				err = ctx.Err()
+			}
+			if err != nil {
+				//关闭已经创建的连接
+				_ = c.closeIdle(c.idle)
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					c.logger.Warnf("simpleConnPool: init pool: aborted after %d connections: %v", i, ctxErr)
+					return nil, ctxErr
+				}
+				c.logger.Errorf("simpleConnPool: init pool: create connection: %v", err)
+				//同时包装 InitPoolErr 与 factory 返回的错误 errors.Is 对两者都成立
+				return nil, fmt.Errorf("%w: %w", InitPoolErr, err)
+			}
+			c.incOpening()
+			idleC := c.newIdleConn(conn, s.close)
+			c.pin(idleC)
+			c.idle = append(c.idle, idleC)
 		}
+		close(c.ready)
 	}
+	if c.minIdle > c.idleFloor {
+		c.idleFloor = c.minIdle
+	}
+	c.startMaintain(poolConfig)
 	return c, nil
 }
 
+//startMaintain 需要时启动后台维护协程 定期回收超时的空闲连接 补充空闲连接 检测连接泄漏 检测空闲连接是否存活
+//后台维护协程只会启动一次 运行间隔由第一次启动时的配置决定
+func (c *connectionPool) startMaintain(poolConfig *Config) {
+	if poolConfig.IdleTimeout <= 0 && c.maxLifetime <= 0 && c.minIdle <= 0 && c.leakThreshold <= 0 && c.healthCheck == nil && !c.idleOverflow {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&c.maintaining, 0, 1) {
+		return
+	}
+	interval := poolConfig.MaintainInterval
+	if interval <= 0 {
+		interval = defaultMaintainInterval
+		if poolConfig.IdleTimeout > 0 {
+			interval = poolConfig.IdleTimeout / 2
+		}
+	}
+	healthInterval := poolConfig.HealthCheckInterval
+	if healthInterval <= 0 {
+		healthInterval = interval
+	}
+	go c.maintain(interval, healthInterval)
+}
+
+//loadSettings 返回当前生效的可修改配置 返回值不会被修改
+func (c *connectionPool) loadSettings() *poolSettings {
+	c.settingsMu.RLock()
+	defer c.settingsMu.RUnlock()
+	return c.settings
+}
+
+//Reconfigure 在不关闭已有连接的情况下修改连接池配置 cfg 不合法时返回错误且不修改任何配置
+//生效的配置项为 IdleTimeout IdleTimeoutJitter WaitTimeout MaxCap MaxIdle BurstCap 以及 Factory FactoryContext Close
+//MaxIdle 还需满足创建连接池时的 NoIdle 与 IdleOverflow
+//Factory 与 Close 只对之后创建的连接生效 已有连接仍使用其创建时的 Close 关闭
+//已经在空闲队列中的连接在下一次归还后才按新的 IdleTimeout 计算失效时间 其他配置项在创建连接池后不能修改 会被忽略
+func (c *connectionPool) Reconfigure(cfg *Config) error {
+	if cfg == nil {
+		return InvalidCapSet
+	}
+	if err := cfg.Check(); err != nil {
+		return err
+	}
+	//连接数上限一次检查并修改 成功后再修改其余配置 失败时不修改任何配置
+	err := c.setLimits(func(l *limits) { *l = configLimits(cfg) })
+	if err == InvalidCapSet || err == PoolClosed {
+		return err
+	}
+	c.settingsMu.Lock()
+	c.settings = newPoolSettings(cfg)
+	c.settingsMu.Unlock()
+	c.startMaintain(cfg)
+	return err
+}
+
+//checkReconfigure 检查 cfg 能否用于 Reconfigure 不做修改 供 ShardedPool 在修改任何分片前检查
+func (c *connectionPool) checkReconfigure(cfg *Config) error {
+	if cfg == nil {
+		return InvalidCapSet
+	}
+	if err := cfg.Check(); err != nil {
+		return err
+	}
+	return c.checkLimits(func(l *limits) { *l = configLimits(cfg) })
+}
+
+//warmup 后台创建 n 个空闲连接 单个连接创建失败时记录日志并继续 完成后关闭 ready
+func (c *connectionPool) warmup(n int32) {
+	defer close(c.ready)
+	for i := int32(0); i < n && !c.isClosed(); i++ {
+		if !c.reserveConn() {
+			return
+		}
+		idleC, err := c.createConn(context.Background())
+		if err != nil {
+			continue
+		}
+		c.pin(idleC)
+		_ = c.recycle(idleC)
+	}
+	c.logger.Debugf("simpleConnPool: warm-up finished, %d idle connections", c.IdleLen())
+}
+
+//WaitReady 阻塞直到初始化空闲连接完成 ctx 结束时返回 ctx.Err() 连接池已关闭时返回 PoolClosed
+func (c *connectionPool) WaitReady(ctx context.Context) error {
+	select {
+	case <-c.ready:
+		if c.isClosed() {
+			return PoolClosed
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 //Get 向连接池中获取一个连接
 func (c *connectionPool) Get() (any, error) {
+	return c.GetContext(context.Background())
+}
+
+//GetContext 向连接池中获取一个连接 等待过程中 ctx 被取消则返回 ctx.Err()
+//调用时 ctx 已经结束则直接返回 ctx.Err() 不会借出空闲连接也不会创建连接
+func (c *connectionPool) GetContext(ctx context.Context) (any, error) {
+	if conn, ok, err := c.getIdleFast(ctx); ok {
+		return conn, err
+	}
+	return c.get(ctx, true, c.loadSettings().waitTimeOut, 0, nil)
+}
+
+//getIdleFast 有空闲连接时的快速路径 借出一个未失效的空闲连接 不读取 WaitTimeout 也不进入获取循环
+//设置了 Validate 或 ctx 带有 GotConn 回调时不适用 未命中时 ok 为 false 由 get 按完整流程处理
+//取出的连接已失效时与完整流程相同 关闭后沿用其占用的连接数重新创建 ok 为 true 时 conn 与 err 即为结果
+func (c *connectionPool) getIdleFast(ctx context.Context) (conn any, ok bool, err error) {
+	if c.validate != nil || c.isClosed() || ctx.Err() != nil {
+		return nil, false, nil
+	}
+	if trace := contextGetTrace(ctx); trace != nil && trace.GotConn != nil {
+		return nil, false, nil
+	}
+	idleC := c.popIdle()
+	if idleC == nil {
+		return nil, false, nil
+	}
+	if c.idleTimeoutExceeded(idleC) || c.lifetimeExceeded(idleC) {
+		fresh, err := c.reconnectExpired(ctx, idleC)
+		if err != nil {
+			return nil, true, err
+		}
+		return c.borrowed(fresh), true, nil
+	}
+	return c.borrowed(idleC), true, nil
+}
+
+//reconnectExpired 关闭一个已失效的空闲连接 沿用其占用的连接数直接重新创建 不会进入等待队列
+func (c *connectionPool) reconnectExpired(ctx context.Context, idleC *idleConn) (*idleConn, error) {
+	c.emit(EventExpire)
+	_ = c.closeRaw(idleC)
+	return c.createConn(ctx)
+}
+
+//GetWithPriority 向连接池中获取一个连接 需要等待时 priority 越大越先获得归还的连接 相同优先级按等待顺序
+//Get 与 GetContext 的优先级为 0 可以为健康检查等关键请求设置更高的优先级
+func (c *connectionPool) GetWithPriority(ctx context.Context, priority int) (any, error) {
+	return c.get(ctx, true, c.loadSettings().waitTimeOut, priority, nil)
+}
+
+//GetWithInfo 向连接池中获取一个连接 同时返回连接是否复用空闲连接 是否新创建以及等待的时间
+func (c *connectionPool) GetWithInfo(ctx context.Context) (any, GetInfo, error) {
+	info := GetInfo{}
+	conn, err := c.get(ctx, true, c.loadSettings().waitTimeOut, 0, &info)
+	return conn, info, err
+}
+
+//tryGetContext 与 TryGet 相同 但创建连接时使用 ctx info 不为空时记录过程信息 供 ShardedPool 依次尝试各分片
+//返回 ErrPoolExhausted 时 ShardedPool 会继续尝试其他分片 此时不触发 ctx 中的 GotConn 回调
+func (c *connectionPool) tryGetContext(ctx context.Context, info *GetInfo) (any, error) {
+	if info == nil {
+		if conn, ok, err := c.getIdleFast(ctx); ok {
+			return conn, err
+		}
+	}
+	trace := contextGetTrace(ctx)
+	if trace != nil && trace.GotConn != nil && info == nil {
+		info = &GetInfo{}
+	}
+	conn, err := c.acquire(ctx, false, 0, 0, info)
+	if info != nil {
+		info.Err = err
+	}
+	if trace != nil && trace.GotConn != nil && err != ErrPoolExhausted {
+		trace.GotConn(*info)
+	}
+	return conn, err
+}
+
+//GetMany 一次获取 n 个连接 全部获取成功或全部失败
+//任一次获取失败 例如等待超时或 ctx 被取消 已经获取的连接会被立即归还并返回错误 不会出现只借出一部分的情况
+//n 大于 MaxCap 时无法一次借出 最终会因超时失败 n 小于等于0时返回空切片
+//多个 GetMany 互相持有部分连接时依靠超时释放 WaitTimeout 小于等于0时应通过 ctx 设置截止时间
+//开启 FairGetMany 时每获取一个连接后让出 有等待中的请求时排在它们之后获取下一个连接
+func (c *connectionPool) GetMany(ctx context.Context, n int) ([]any, error) {
+	return getMany(c, n, func(i int) (any, error) {
+		if c.fairGetMany && i > 0 {
+			return c.getBehindWaiters(ctx)
+		}
+		return c.GetContext(ctx)
+	})
+}
+
+//getBehindWaiters 让出处理器后获取一个连接 有等待中的请求时直接排到等待队列末尾 不与它们争抢空闲连接或空余连接数
+func (c *connectionPool) getBehindWaiters(ctx context.Context) (any, error) {
+	runtime.Gosched()
+	if c.waitingLen() == 0 {
+		return c.GetContext(ctx)
+	}
+	start := c.clock.Now()
+	idleC, _, err := c.wait(ctx, c.loadSettings().waitTimeOut, 0, false)
+	c.counters.recordWait(c.clock.Now().Sub(start))
+	if err != nil {
+		return nil, err
+	}
+	return c.borrowed(idleC), nil
+}
+
+//getMany 通过 get 依次获取 n 个连接 i 为已经获取的连接数 失败时通过 p 归还已经获取的连接
+func getMany(p Pool, n int, get func(i int) (any, error)) ([]any, error) {
+	conns := make([]any, 0, n)
+	for len(conns) < n {
+		conn, err := get(len(conns))
+		if err != nil {
+			for _, got := range conns {
+				_ = p.Put(got)
+			}
+			return nil, err
+		}
+		conns = append(conns, conn)
+	}
+	return conns, nil
+}
+
+//Use 获取一个连接并以它调用 fn fn 返回后归还连接 返回 fn 的错误
+//fn 返回的错误包装了 ErrBrokenConn 时连接被 Invalidate 关闭而不是归还 fn panic 时连接同样被归还 panic 继续向上传递
+func (c *connectionPool) Use(ctx context.Context, fn func(conn any) error) error {
+	return use(c, ctx, fn)
+}
+
+//use 通过 p 获取连接并调用 fn 保证连接在 fn 返回或 panic 后被归还或关闭
+func use(p Pool, ctx context.Context, fn func(conn any) error) (err error) {
+	conn, err := p.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	//fn panic 时 err 为 nil 连接被归还
+	defer func() {
+		if errors.Is(err, ErrBrokenConn) {
+			_ = p.Invalidate(conn)
+			return
+		}
+		_ = p.Put(conn)
+	}()
+	return fn(conn)
+}
+
+//TryGet 向连接池中获取一个连接 既没有空闲连接也无法创建时立即返回 ErrPoolExhausted 不会进入等待队列
+func (c *connectionPool) TryGet() (any, error) {
+	if conn, ok, err := c.getIdleFast(context.Background()); ok {
+		return conn, err
+	}
+	return c.get(context.Background(), false, 0, 0, nil)
+}
+
+//GetWithTimeout 向连接池中获取一个连接 本次获取使用 d 作为最大等待时间
+//d 为 0 时使用连接池配置的 WaitTimeout d 小于 0 时与 TryGet 相同 不进行等待
+func (c *connectionPool) GetWithTimeout(d time.Duration) (any, error) {
+	switch {
+	case d == 0:
+		return c.Get()
+	case d < 0:
+		return c.TryGet()
+	}
+	return c.get(context.Background(), true, d, 0, nil)
+}
+
+//get 获取连接 wait 为 false 时不进入等待队列 否则最多等待 waitTimeout
+//info 不为空时记录本次获取的过程信息
+func (c *connectionPool) get(ctx context.Context, wait bool, waitTimeout time.Duration, priority int, info *GetInfo) (any, error) {
+	trace := contextGetTrace(ctx)
+	if trace == nil || trace.GotConn == nil {
+		conn, err := c.acquire(ctx, wait, waitTimeout, priority, info)
+		if info != nil {
+			info.Err = err
+		}
+		return conn, err
+	}
+	if info == nil {
+		info = &GetInfo{}
+	}
+	conn, err := c.acquire(ctx, wait, waitTimeout, priority, info)
+	info.Err = err
+	trace.GotConn(*info)
+	return conn, err
+}
+
+//acquire 获取连接 info 不为空时记录连接的来源与等待时间
+func (c *connectionPool) acquire(ctx context.Context, wait bool, waitTimeout time.Duration, priority int, info *GetInfo) (any, error) {
+	//ctx 已经结束的请求不占用任何连接
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	failures := int32(0)
 	for {
-		select {
+		if c.isClosed() {
+			return nil, PoolClosed
+		}
 		//获取空闲队列里面的链接
-		case idleC, ok := <-c.idleQueue:
-			if ok {
-				//检测连接是否超时
-				if c.idleTimeOut > 0 {
-					if time.Now().Sub(idleC.lastActiveTime) > c.idleTimeOut {
-						//关闭连接
-						_ = c.Close(idleC)
-						continue
-					}
+		if idleC := c.popIdle(); idleC != nil {
+			//连接超过最大空闲时间或最大存活时间 关闭后沿用其占用的连接数直接重新创建 不会进入等待队列
+			if c.idleTimeoutExceeded(idleC) || c.lifetimeExceeded(idleC) {
+				fresh, err := c.reconnectExpired(ctx, idleC)
+				if err != nil {
+					return nil, err
 				}
-				return idleC, nil
+				info.setSource(SourceCreated)
+				return c.borrowed(fresh), nil
 			}
-			return nil, PoolClosed
-		default:
-			//未获取到链接 且 还可以创建 则创建一个连接
-			if atomic.AddInt32(&c.openingConn, 1) < c.maxActiveConn {
-				//创建连接
-				return c.factory()
-			}
-			//无法创建 则放入请求队列
-			atomic.AddInt32(&c.openingConn, -1)
-
-			req := connReq{
-				//unbuffered channel
-				idleConn: make(chan any),
-			}
-			ticker := time.NewTicker(c.waitTimeOut)
-			select {
-			//放入等待的channel中
-			case c.reqQueue <- req:
-				select {
-				case conn := <-req.idleConn:
-					return conn, nil
-				case <-ticker.C:
-					//从等待队列中 抛弃这个请求
-					req.abandon = true
-					return nil, GetConnectionTimeout
+			//检测连接是否可用
+			if c.validate != nil && c.validate(idleC.connection) != nil {
+				_ = c.closeConn(idleC)
+				//连续检测失败时不再继续关闭与重试 避免所有连接都已损坏时长时间循环 空出的连接数交给等待中的请求
+				if failures++; c.maxValidateFailures > 0 && failures >= c.maxValidateFailures {
+					c.replaceForWaiters()
+					return nil, ErrNoHealthyConnections
 				}
+				continue
+			}
+			info.setSource(SourceIdle)
+			return c.borrowed(idleC), nil
+		}
+		//未获取到链接 且 还可以创建 则创建一个连接
+		if c.reserveConn() {
+			//创建连接
+			idleC, err := c.createConn(ctx)
+			if err != nil {
+				return nil, err
+			}
+			info.setSource(SourceCreated)
+			return c.borrowed(idleC), nil
+		}
+		//达到最大连接数但未达到 BurstCap 时创建突发连接 不进入等待队列
+		if c.reserveBurst() {
+			idleC, err := c.createConn(ctx)
+			if err != nil {
+				return nil, err
 			}
+			c.markBurst(idleC)
+			atomic.AddInt64(&c.counters.totalBursts, 1)
+			info.setSource(SourceCreated)
+			return c.borrowed(idleC), nil
+		}
+		//无法创建 则放入请求队列
+		if !wait {
+			return nil, ErrPoolExhausted
 		}
+		start := c.clock.Now()
+		idleC, retry, err := c.wait(ctx, waitTimeout, priority, true)
+		waited := c.clock.Now().Sub(start)
+		info.addWait(waited)
+		if retry {
+			continue
+		}
+		c.counters.recordWait(waited)
+		if err != nil {
+			return nil, err
+		}
+		info.setSource(SourceWaited)
+		return c.borrowed(idleC), nil
 	}
-
 }
 
-//Put 向连接池中放入一个连接
-func (c *connectionPool) Put(conn any) error {
-	if conn == nil {
-		return ConnectionIsNull
+//wait 按 priority 加入等待队列 等待其他请求归还连接 最多等待 waitTimeout
+//recheck 为 true 时加入队列前发现有空闲连接或可以创建连接则返回 retry 为 true 由调用方重新获取
+func (c *connectionPool) wait(ctx context.Context, waitTimeout time.Duration, priority int, recheck bool) (idleC *idleConn, retry bool, err error) {
+	//waitTimeout 小于等于0时不设置超时 timeoutC 为 nil 永远不会触发
+	//QueueFullBlock 等待空位的时间不计入 waitTimeout 占用空位后才开始计时
+	start := c.clock.Now()
+	var waitTimer timer
+	defer func() {
+		if waitTimer != nil {
+			waitTimer.Stop()
+		}
+	}()
+	var timeoutC <-chan time.Time
+	if waitTimeout > 0 && c.onQueueFull != QueueFullBlock {
+		waitTimer = c.clock.NewTimer(waitTimeout)
+		timeoutC = waitTimer.C()
+	}
+	if err := c.acquireSlot(ctx, timeoutC); err != nil {
+		return nil, false, err
+	}
+	if waitTimeout > 0 && waitTimer == nil {
+		waitTimer = c.clock.NewTimer(waitTimeout)
+		timeoutC = waitTimer.C()
+	}
+
+	//wait 返回时请求一定已经出队且 idleConn 已被取空 可以安全地复用
+	req := connReqPool.Get().(*connReq)
+	defer func() {
+		req.err = nil
+		connReqPool.Put(req)
+	}()
+	c.waitMu.Lock()
+	if c.isClosed() {
+		c.waitMu.Unlock()
+		<-c.waitSlots
+		return nil, false, PoolClosed
 	}
-Try:
+	//持有 waitMu 再次检查 避免与归还连接或释放连接数的操作交错导致请求错过唤醒
+	if recheck && (c.IdleLen() > 0 || c.belowBurstCap(atomic.LoadInt32(&c.openingConn))) {
+		c.waitMu.Unlock()
+		<-c.waitSlots
+		return nil, true, nil
+	}
+	req.priority = priority
+	c.enqueue(req)
+	c.waitMu.Unlock()
+	atomic.AddInt32(&c.waiting, 1)
+	defer atomic.AddInt32(&c.waiting, -1)
+
 	select {
-	case req, ok := <-c.reqQueue:
-		if !ok {
-			return PoolClosed
+	case idleC := <-req.idleConn:
+		//nil 连接表示为该请求创建连接失败
+		if idleC == nil {
+			return nil, false, req.err
 		}
-		if req.abandon {
-			//此获取链接请求被抛弃
-			goto Try
+		return idleC, false, nil
+	case <-c.done:
+		if idleC := c.leave(req); idleC != nil {
+			_ = c.closeConn(idleC)
 		}
-		req.idleConn <- conn
+		return nil, false, PoolClosed
+	case <-timeoutC:
+		//离开队列前已经被分配了连接 直接使用
+		if idleC := c.leave(req); idleC != nil {
+			return idleC, false, nil
+		}
+		atomic.AddInt64(&c.counters.totalTimeouts, 1)
+		c.emit(EventTimeout)
+		c.logger.Warnf("simpleConnPool: wait for connection timed out after %v", waitTimeout)
+		return nil, false, &WaitTimeoutError{Timeout: waitTimeout, Waited: c.clock.Now().Sub(start)}
+	case <-ctx.Done():
+		if idleC := c.leave(req); idleC != nil {
+			_ = c.recycle(idleC)
+		}
+		return nil, false, ctx.Err()
+	}
+}
+
+//acquireSlot 占用等待队列的一个空位 等待队列已满时按 onQueueFull 处理
+//QueueFullTimeout 与等待连接共用 timeoutC 即占用空位与等待连接的总时间不超过 WaitTimeout
+func (c *connectionPool) acquireSlot(ctx context.Context, timeoutC <-chan time.Time) error {
+	select {
+	case c.waitSlots <- struct{}{}:
+		return nil
 	default:
-		//无等待连接的请求 则放入空闲队列中
-		select {
-		case c.idleQueue <- &idleConn{
-			connection:     conn,
-			lastActiveTime: time.Now(),
-		}:
-			return nil
-		default:
-			//空闲队列已经满了 则关闭连接
-			atomic.AddInt32(&c.openingConn, -1)
-			return c.Close(conn)
+	}
+	if c.onQueueFull == QueueFullReject {
+		c.logger.Warnf("simpleConnPool: wait queue is full (%d waiting)", cap(c.waitSlots))
+		return ErrWaitQueueFull
+	}
+	var slotTimeoutC <-chan time.Time
+	if c.onQueueFull == QueueFullTimeout {
+		slotTimeoutC = timeoutC
+	}
+	select {
+	case c.waitSlots <- struct{}{}:
+		return nil
+	case <-slotTimeoutC:
+		c.logger.Warnf("simpleConnPool: wait queue stayed full (%d waiting)", cap(c.waitSlots))
+		return ErrWaitQueueFull
+	case <-c.done:
+		return PoolClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//enqueue 按优先级将请求插入等待队列 必须持有 waitMu
+//队列按优先级从高到低排列 相同优先级按加入顺序 从队尾向前查找 常见的同优先级请求为 O(1)
+func (c *connectionPool) enqueue(req *connReq) {
+	for e := c.waiters.Back(); e != nil; e = e.Prev() {
+		if e.Value.(*connReq).priority >= req.priority {
+			req.elem = c.waiters.InsertAfter(req, e)
+			return
+		}
+	}
+	req.elem = c.waiters.PushFront(req)
+}
+
+//leave 将放弃等待的请求移出等待队列 O(1) 完成 不影响其他请求的顺序
+//请求已经出队时返回已经交给它的连接 由调用方处理
+func (c *connectionPool) leave(req *connReq) *idleConn {
+	c.waitMu.Lock()
+	defer c.waitMu.Unlock()
+	if req.elem != nil {
+		c.waiters.Remove(req.elem)
+		req.elem = nil
+		<-c.waitSlots
+		return nil
+	}
+	return <-req.idleConn
+}
+
+//waitingLen 返回等待获取连接的请求数
+func (c *connectionPool) waitingLen() int {
+	c.waitMu.Lock()
+	defer c.waitMu.Unlock()
+	return c.waiters.Len()
+}
+
+//Put 向连接池中放入一个连接 conn 为 Get 返回的原始连接 或 GetConn 返回的 *PooledConn 句柄
+//conn 不是由本连接池借出或已经归还时返回 ErrUnknownConnection
+//与 Shutdown 并发调用是安全的 连接池已关闭时连接被直接关闭并返回 PoolClosed
+//Put 不会向已关闭的 channel 发送 交给等待请求与放入空闲队列时分别在 waitMu 与 idleMu 下检查关闭标志 发布事件时在 eventsMu 读锁下检查
+func (c *connectionPool) Put(conn any) error {
+	if conn == nil {
+		return ConnectionIsNull
+	}
+	if pc, ok := conn.(*PooledConn); ok {
+		return pc.Release()
+	}
+	idleC, ok := c.release(conn)
+	if !ok {
+		return ErrUnknownConnection
+	}
+	if c.onPut != nil {
+		c.onPut(conn)
+	}
+	c.emit(EventReturn)
+	if idleC.uncounted {
+		return c.closeUncounted(idleC)
+	}
+	atomic.AddInt32(&c.activeConn, -1)
+	//连接池已经关闭 关闭该连接 避免泄漏
+	if c.isClosed() {
+		_ = c.closeConn(idleC)
+		return PoolClosed
+	}
+	//不复用连接或突发连接时直接关闭 等待中的请求由 replaceForWaiters 创建新连接
+	if c.noIdle || idleC.burst {
+		err := c.closeConn(idleC)
+		c.replaceForWaiters()
+		return err
+	}
+	//超过最大存活时间或最多借出次数的连接直接关闭
+	if c.lifetimeExceeded(idleC) || c.usageExceeded(idleC) {
+		c.emit(EventExpire)
+		err := c.closeConn(idleC)
+		c.replaceForWaiters()
+		return err
+	}
+	//归还时检测失败的连接直接关闭 不会被下一个请求借出
+	if err := c.checkOnPut(conn); err != nil {
+		c.logger.Debugf("simpleConnPool: validation on put failed, closing connection%s: %v", metaSuffix(conn), err)
+		closeErr := c.closeConn(idleC)
+		c.replaceForWaiters()
+		return closeErr
+	}
+	//重置失败的连接状态未知 关闭而不是交给下一个请求
+	if c.reset != nil {
+		if err := c.reset(conn); err != nil {
+			c.logger.Debugf("simpleConnPool: reset on put failed, closing connection%s: %v", metaSuffix(conn), err)
+			closeErr := c.closeConn(idleC)
+			c.replaceForWaiters()
+			return closeErr
 		}
 	}
+	c.touch(idleC)
+	return c.recycle(idleC)
+}
+
+//checkOnPut 开启 ValidateOnPut 时检测归还的连接
+func (c *connectionPool) checkOnPut(conn any) error {
+	if !c.validateOnPut {
+		return nil
+	}
+	return c.checkConn(conn)
+}
+
+//checkConn 检测连接是否可用 优先使用 validate 未设置时使用 healthCheck 都未设置时返回 nil
+func (c *connectionPool) checkConn(conn any) error {
+	if c.validate != nil {
+		return c.validate(conn)
+	}
+	if c.healthCheck != nil {
+		return c.healthCheck(conn)
+	}
 	return nil
 }
 
-//Close 关闭连接
-func (c *connectionPool) Close(conn any) error {
-	if c.close == nil {
+//recycle 将一个可复用的连接交给等待中的请求 没有等待的请求则放入空闲队列
+//空闲队列已满时保留最近活跃的连接 关闭其中最早变为空闲的连接
+func (c *connectionPool) recycle(idleC *idleConn) error {
+	evicted, ok, closed := c.handOff(idleC)
+	//关闭连接时不持有任何锁
+	if ok {
+		if evicted != nil {
+			return c.closeConn(evicted)
+		}
 		return nil
 	}
+	if closed {
+		_ = c.closeConn(idleC)
+		return PoolClosed
+	}
+	//空闲队列已经满了 且该连接比队列中的连接都旧 则关闭连接 连接数由 closeConn 释放
+	return c.closeConn(idleC)
+}
+
+//handOff 将连接交给队头即优先级最高且最早等待的请求 没有等待的请求则放入空闲队列 不会阻塞
+//出队与发送在同一次持有 waitMu 期间完成 请求的 channel 缓冲为1且每次只会收到一个连接 因此发送总是立即完成
+//请求在收到连接后放弃等待时 leave 会取回该连接并由请求方归还 连接不会丢失
+//返回 ok 为 false 表示连接未被接收 需要由调用方关闭 closed 表示原因是连接池已关闭
+//evicted 为放入空闲队列时被挤出的最旧连接 需要由调用方关闭
+func (c *connectionPool) handOff(idleC *idleConn) (evicted *idleConn, ok bool, closed bool) {
+	c.waitMu.Lock()
+	defer c.waitMu.Unlock()
+	if c.isClosed() {
+		return nil, false, true
+	}
+	if front := c.waiters.Front(); front != nil {
+		req := c.waiters.Remove(front).(*connReq)
+		req.elem = nil
+		<-c.waitSlots
+		//缓冲为1 不会阻塞
+		req.idleConn <- idleC
+		return nil, true, false
+	}
+	//突发连接只交给等待中的请求 不会放入空闲队列
+	if idleC.burst {
+		return nil, false, false
+	}
+	//无等待连接的请求 则放入空闲队列中
+	if evicted, ok = c.pushIdle(idleC); ok {
+		return evicted, true, false
+	}
+	return nil, false, c.isClosed()
+}
+
+//Ping 借出一个连接 没有空闲连接时按 ctx 创建或等待 执行 Validate 或 HealthCheck 后归还 用于就绪检测
+//连接可用时返回 nil 否则返回获取连接或检测的错误 检测失败的连接会被关闭
+func (c *connectionPool) Ping(ctx context.Context) error {
+	conn, err := c.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	if err := c.checkConn(conn); err != nil {
+		_ = c.Invalidate(conn)
+		return err
+	}
+	return c.Put(conn)
+}
+
+//CloseConn 关闭一个借出的连接 conn 为 Get 返回的原始连接 与 Invalidate 相同
+func (c *connectionPool) CloseConn(conn any) error {
+	return c.Invalidate(conn)
+}
+
+//Close 关闭一个借出的连接
+//
+//Deprecated: Close 容易被误解为关闭整个连接池 请使用 CloseConn 关闭单个连接 使用 Shutdown 关闭连接池
+//下一个版本中 Close 将改为 Close() error 用于关闭整个连接池
+func (c *connectionPool) Close(conn any) error {
+	return c.CloseConn(conn)
+}
+
+//Invalidate 关闭一个已损坏的借出连接 连接使用中出现 I/O 错误时应调用此方法而不是 Put
+//连接被关闭并释放其占用的连接数 有等待中的请求时在后台为其创建新的连接
+//conn 不是由本连接池借出或已经归还时返回 ErrUnknownConnection
+func (c *connectionPool) Invalidate(conn any) error {
+	idleC, ok := c.release(conn)
+	if !ok {
+		return ErrUnknownConnection
+	}
+	if idleC.uncounted {
+		return c.closeUncounted(idleC)
+	}
+	atomic.AddInt32(&c.activeConn, -1)
+	err := c.closeConn(idleC)
+	c.replaceForWaiters()
+	return err
+}
+
+//RefreshConn 关闭一个借出的连接 沿用其占用的连接数创建一个新连接并借出 旧连接无需再归还
+//用于会话中途需要新连接的场景 例如认证过期 创建连接时按配置重试 创建失败时释放占用的连接数并返回错误
+func (c *connectionPool) RefreshConn(old any) (any, error) {
+	if old == nil {
+		return nil, ConnectionIsNull
+	}
+	oldC, ok := c.release(old)
+	if !ok {
+		return nil, ErrUnknownConnection
+	}
+	if oldC.uncounted {
+		_ = c.closeUncounted(oldC)
+		return c.GetUncounted()
+	}
+	atomic.AddInt32(&c.activeConn, -1)
+	if c.isClosed() {
+		_ = c.closeConn(oldC)
+		return nil, PoolClosed
+	}
+	_ = c.closeRaw(oldC)
+	idleC, err := c.createConn(context.Background())
+	if err != nil {
+		c.replaceForWaiters()
+		return nil, err
+	}
+	//沿用突发连接的连接数创建的连接仍然是突发连接
+	if oldC.burst {
+		c.markBurst(idleC)
+	}
+	return c.borrowed(idleC), nil
+}
+
+//GetUncounted 创建一个不计入 MaxCap 的连接并借出 例如专用的监控连接 不会等待也不会影响普通的 Get
+//连接同样通过 Put 或 Invalidate 归还 归还时直接关闭而不会放入空闲队列 当前数量见 Stats 的 UncountedConn
+func (c *connectionPool) GetUncounted() (any, error) {
+	if c.isClosed() {
+		return nil, PoolClosed
+	}
+	s := c.loadSettings()
+	conn, err := c.dialRetry(context.Background(), s)
+	if err != nil {
+		return nil, err
+	}
+	c.emit(EventCreate)
+	idleC := c.newIdleConn(conn, s.close)
+	idleC.uncounted = true
+	atomic.AddInt32(&c.uncountedConn, 1)
+	return c.borrowed(idleC), nil
+}
+
+//closeUncounted 关闭一个不计入最大连接数的连接
+func (c *connectionPool) closeUncounted(idleC *idleConn) error {
+	atomic.AddInt32(&c.uncountedConn, -1)
+	return c.closeRaw(idleC)
+}
 
-	atomic.AddInt32(&c.openingConn, -1)
-	return c.close(conn)
+//replaceForWaiters 连接被关闭后 如果有等待中的请求 在后台为其创建新的连接
+func (c *connectionPool) replaceForWaiters() {
+	if c.waitingLen() > 0 && !c.isClosed() {
+		go c.fillWaiters()
+	}
+}
+
+//pin 开启 PinInitial 且固定连接不足 pinLimit 个时将新创建的连接标记为固定连接
+func (c *connectionPool) pin(idleC *idleConn) {
+	if !c.pinInitial {
+		return
+	}
+	limit := c.pinLimit()
+	for {
+		n := atomic.LoadInt32(&c.pinnedConn)
+		if n >= limit {
+			return
+		}
+		if atomic.CompareAndSwapInt32(&c.pinnedConn, n, n+1) {
+			idleC.pinned = true
+			return
+		}
+	}
+}
+
+//pinLimit 返回最多固定的连接数 即 initialCap 与空闲队列容量中较小的一个
+//SetMaxIdle 调小后固定连接不会超过空闲队列的容量 否则多出的固定连接归还时被关闭 又被后台维护协程重新创建
+func (c *connectionPool) pinLimit() int32 {
+	c.idleMu.Lock()
+	defer c.idleMu.Unlock()
+	if limit := c.idleLimit(); limit < c.initialCap {
+		return limit
+	}
+	return c.initialCap
+}
+
+//closeConn 关闭连接并释放其占用的连接数
+//无论关闭方法是否返回错误 连接都视为已关闭 连接数总是被释放
+func (c *connectionPool) closeConn(idleC *idleConn) error {
+	c.decOpening()
+	return c.closeRaw(idleC)
+}
+
+//closeRaw 关闭连接但不释放其占用的连接数 用于沿用连接数重新创建连接
+//关闭方法返回的错误总是交给 OnClose 记录日志并通过 EventClose 发布 即使调用方忽略了返回值也可以观察到
+func (c *connectionPool) closeRaw(idleC *idleConn) error {
+	if idleC.pinned {
+		atomic.AddInt32(&c.pinnedConn, -1)
+	}
+	if idleC.burst {
+		atomic.AddInt32(&c.burstConn, -1)
+	}
+	conn := idleC.connection
+	err := idleC.close(conn)
+	if err != nil {
+		c.logger.Warnf("simpleConnPool: close connection%s: %v", metaSuffix(conn), err)
+	}
+	c.emitErr(EventClose, err)
+	if c.onClose != nil {
+		c.onClose(conn, err)
+	}
+	return err
+}
+
+//createConn 使用已占用的连接数创建一个连接 创建失败时按配置重试 最终失败时释放占用的连接数
+//释放的连接数交给等待中的请求 避免请求在有空余连接数时仍然一直等待
+//熔断中不会调用 factory 直接返回 ErrCircuitOpen
+func (c *connectionPool) createConn(ctx context.Context) (*idleConn, error) {
+	s := c.loadSettings()
+	conn, err := c.dialRetry(ctx, s)
+	if err != nil {
+		c.decOpening()
+		c.replaceForWaiters()
+		return nil, err
+	}
+	c.emit(EventCreate)
+	return c.newIdleConn(conn, s.close), nil
+}
+
+//dialRetry 调用 dial 使用 s 中的 factory 创建连接 失败时按配置重试 不涉及连接数
+func (c *connectionPool) dialRetry(ctx context.Context, s *poolSettings) (any, error) {
+	conn, err := c.dial(ctx, s)
+	backoff := c.factoryRetryBackoff
+	for i := 0; err != nil && err != ErrCircuitOpen && i < c.factoryRetries; i++ {
+		atomic.AddInt64(&c.counters.totalFactoryErrors, 1)
+		c.logger.Debugf("simpleConnPool: create connection failed, retrying in %v: %v", backoff, err)
+		if waitErr := c.sleep(ctx, backoff); waitErr != nil {
+			return nil, waitErr
+		}
+		backoff *= 2
+		conn, err = c.dial(ctx, s)
+	}
+	if err != nil {
+		if err == ErrCircuitOpen {
+			return nil, err
+		}
+		atomic.AddInt64(&c.counters.totalFactoryErrors, 1)
+		c.logger.Errorf("simpleConnPool: create connection: %v", err)
+		return nil, err
+	}
+	return conn, nil
+}
+
+//dial 经过熔断器调用 factory 创建连接 并记录创建结果
+func (c *connectionPool) dial(ctx context.Context, s *poolSettings) (any, error) {
+	if c.breaker == nil {
+		return c.callFactory(ctx, s)
+	}
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+	conn, err := c.callFactory(ctx, s)
+	if err != nil {
+		c.breaker.failure()
+		return nil, err
+	}
+	c.breaker.success()
+	return conn, nil
+}
+
+//callFactory 调用 s 中的 factory 设置了 createTimeout 时最多等待 createTimeout 超时返回 ErrCreateTimeout
+//factory 在另一个协程中执行 超时后才创建成功的连接由该协程关闭 FactoryContext 会收到到期的 ctx
+func (c *connectionPool) callFactory(ctx context.Context, s *poolSettings) (any, error) {
+	if c.createTimeout <= 0 {
+		return s.factory(ctx)
+	}
+	createCtx, cancel := context.WithTimeout(ctx, c.createTimeout)
+	defer cancel()
+	conn, err := c.factoryWithin(createCtx, s)
+	if err != nil && createCtx.Err() != nil && ctx.Err() == nil {
+		c.logger.Warnf("simpleConnPool: create connection timed out after %v", c.createTimeout)
+		return nil, ErrCreateTimeout
+	}
+	return conn, err
+}
+
+//factoryWithin 在另一个协程中调用 s 中的 factory 最多等待到 ctx 结束 ctx 先结束时返回 ctx.Err()
+//不使用 ctx 的 Factory 阻塞时也能按时返回 ctx 结束后才创建成功的连接由该协程关闭 ctx 不会结束时直接调用
+func (c *connectionPool) factoryWithin(ctx context.Context, s *poolSettings) (any, error) {
+	if ctx.Done() == nil {
+		return s.factory(ctx)
+	}
+	type result struct {
+		conn any
+		err  error
+	}
+	//缓冲为1 ctx 结束后协程仍可以发送结果并退出
+	done := make(chan result, 1)
+	go func() {
+		conn, err := s.factory(ctx)
+		done <- result{conn, err}
+	}()
+	select {
+	case r := <-done:
+		return r.conn, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-done; r.err == nil {
+				_ = c.closeRaw(c.newIdleConn(r.conn, s.close))
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+//sleep 等待 d 时间 ctx 结束或连接池关闭时提前返回错误
+func (c *connectionPool) sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := c.clock.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.done:
+		return PoolClosed
+	}
+}
+
+//reserveConn 在未达到最大连接数时占用一个连接数 返回是否占用成功
+func (c *connectionPool) reserveConn() bool {
+	if c.belowMaxCap(c.incOpening() - 1) {
+		return true
+	}
+	c.decOpening()
+	return false
+}
+
+//reserveBurst 达到最大连接数后 在连接数未达到 burstCap 时占用一个突发连接数 返回是否占用成功
+func (c *connectionPool) reserveBurst() bool {
+	burstCap := atomic.LoadInt32(&c.burstCap)
+	if burstCap <= 0 {
+		return false
+	}
+	if c.incOpening()-1 < burstCap {
+		return true
+	}
+	c.decOpening()
+	return false
+}
+
+//reserveSlot 占用一个连接数 达到最大连接数时尝试占用突发连接数 burst 表示占用的是突发连接数
+func (c *connectionPool) reserveSlot() (burst, ok bool) {
+	if c.reserveConn() {
+		return false, true
+	}
+	if c.reserveBurst() {
+		return true, true
+	}
+	return false, false
+}
+
+//belowBurstCap 返回在已有 opening 个连接时能否再创建一个连接 包括突发连接
+func (c *connectionPool) belowBurstCap(opening int32) bool {
+	burstCap := atomic.LoadInt32(&c.burstCap)
+	return c.belowMaxCap(opening) || burstCap > 0 && opening < burstCap
+}
+
+//markBurst 将占用突发连接数创建的连接标记为突发连接
+func (c *connectionPool) markBurst(idleC *idleConn) {
+	idleC.burst = true
+	atomic.AddInt32(&c.burstConn, 1)
+}
+
+//belowMaxCap 返回在已有 opening 个连接时能否再创建一个连接 最大连接数小于等于0表示不限制
+func (c *connectionPool) belowMaxCap(opening int32) bool {
+	maxCap := atomic.LoadInt32(&c.maxActiveConn)
+	return maxCap <= 0 || opening < maxCap
+}
+
+//incOpening 增加一个正在运行的连接数 返回增加后的值
+//openingConn 只能通过 incOpening 与 decOpening 修改 每个连接从占用到关闭恰好各调用一次
+func (c *connectionPool) incOpening() int32 {
+	return atomic.AddInt32(&c.openingConn, 1)
+}
+
+//decOpening 释放一个正在运行的连接数 使用 pooldebug 构建标签时 计数小于0将会 panic
+func (c *connectionPool) decOpening() {
+	checkOpening(atomic.AddInt32(&c.openingConn, -1))
+}
+
+//borrowed 记录一次成功的连接借出 返回原始连接
+func (c *connectionPool) borrowed(idleC *idleConn) any {
+	var stack []byte
+	if c.leakStack {
+		stack = debug.Stack()
+	}
+	idleC.usage++
+	c.borrowedConns.Store(idleC.connection, &borrowRecord{idleC: idleC, at: c.clock.Now(), stack: stack})
+
+	if !idleC.uncounted {
+		c.notePeak(atomic.AddInt32(&c.activeConn, 1))
+	}
+	atomic.AddInt64(&c.counters.totalGets, 1)
+	c.emit(EventBorrow)
+	if c.onGet != nil {
+		c.onGet(idleC.connection)
+	}
+	return idleC.connection
+}
+
+//release 移除一个已借出连接的记录 返回其连接包装
+//连接不是由本连接池借出或已经归还时 ok 为 false
+func (c *connectionPool) release(conn any) (idleC *idleConn, ok bool) {
+	v, ok := c.borrowedConns.LoadAndDelete(conn)
+	if !ok {
+		return nil, false
+	}
+	return v.(*borrowRecord).idleC, true
+}
+
+//borrowedLen 返回借出记录的数量 包括不计入最大连接数的连接
+func (c *connectionPool) borrowedLen() int {
+	n := 0
+	c.borrowedConns.Range(func(any) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+//idleTimeoutExceeded 连接是否已经超过最大空闲时间
+//Reconfigure 开启 IdleTimeout 前放入空闲队列的连接没有失效时间 不会失效
+func (c *connectionPool) idleTimeoutExceeded(idleC *idleConn) bool {
+	return !idleC.pinned && c.loadSettings().idleTimeOut > 0 && !idleC.idleDeadline.IsZero() && c.clock.Now().After(idleC.idleDeadline)
+}
+
+//touch 连接变为空闲时调用 记录最近活跃时间 并按 IdleTimeout 与随机浮动计算连接的空闲失效时间
+func (c *connectionPool) touch(idleC *idleConn) {
+	now := c.clock.Now()
+	idleC.lastActiveTime = now
+	s := c.loadSettings()
+	if s.idleTimeOut <= 0 {
+		idleC.idleDeadline = time.Time{}
+		return
+	}
+	timeout := s.idleTimeOut
+	if s.idleTimeoutJitter > 0 {
+		timeout += time.Duration(rand.Int63n(2*int64(s.idleTimeoutJitter)+1)) - s.idleTimeoutJitter
+	}
+	idleC.idleDeadline = now.Add(timeout)
+}
+
+//lifetimeExceeded 连接是否已经超过最大存活时间
+func (c *connectionPool) lifetimeExceeded(idleC *idleConn) bool {
+	return !idleC.pinned && c.maxLifetime > 0 && c.clock.Now().Sub(idleC.createdAt) > c.maxLifetime
+}
+
+//usageExceeded 判断连接是否已达到最多借出次数
+func (c *connectionPool) usageExceeded(idleC *idleConn) bool {
+	return !idleC.pinned && c.maxUsage > 0 && idleC.usage >= c.maxUsage
+}
+
+//newIdleConn 包装一个新创建的连接 close 为创建连接时生效的关闭方法
+func (c *connectionPool) newIdleConn(conn any, close func(any) error) *idleConn {
+	idleC := &idleConn{
+		connection: conn,
+		close:      close,
+		createdAt:  c.clock.Now(),
+	}
+	c.touch(idleC)
+	return idleC
+}
+
+//Shutdown 关闭整个连接池 关闭所有空闲连接并唤醒所有等待中的请求
+//空闲连接由最多 ShutdownConcurrency 个协程并发关闭 返回所有关闭错误的合并
+//关闭之后 Get 与 Put 都将返回 PoolClosed 重复调用不会产生任何效果
+func (c *connectionPool) Shutdown() error {
+	return c.shutdown(context.Background())
+}
+
+//shutdown 关闭连接池 ctx 结束时不再等待尚未完成的关闭 返回 ErrDrainTimeout 剩余的连接在后台继续关闭
+func (c *connectionPool) shutdown(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return nil
+	}
+	//先唤醒所有等待中的请求
+	close(c.done)
+
+	c.idleMu.Lock()
+	idle := c.idle
+	c.idle = nil
+	c.idleMu.Unlock()
+	err := c.closeConcurrently(ctx, idle)
+	c.logger.Debugf("simpleConnPool: shutdown, closed %d idle connections", len(idle))
+	c.closeEvents()
+	return err
+}
+
+//closeConcurrently 使用最多 shutdownConcurrency 个协程关闭 idle 中的连接 返回所有关闭错误的合并
+//ctx 结束时立即返回 ErrDrainTimeout 与已经发生的错误 未完成的关闭在后台继续
+func (c *connectionPool) closeConcurrently(ctx context.Context, idle []*idleConn) error {
+	errc := make(chan error, len(idle))
+	sem := make(chan struct{}, c.shutdownConcurrency)
+	go func() {
+		for _, idleC := range idle {
+			sem <- struct{}{}
+			go func(idleC *idleConn) {
+				defer func() { <-sem }()
+				errc <- c.closeConn(idleC)
+			}(idleC)
+		}
+	}()
+	var errs []error
+	for range idle {
+		select {
+		case err := <-errc:
+			if err != nil {
+				errs = append(errs, err)
+			}
+		case <-ctx.Done():
+			c.logger.Warnf("simpleConnPool: shutdown timed out while closing idle connections")
+			if len(errs) == 0 {
+				return ErrDrainTimeout
+			}
+			return errors.Join(append(errs, ErrDrainTimeout)...)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+const (
+	//drainPollInterval DrainContext 检查借出连接是否全部归还的间隔
+	drainPollInterval = 10 * time.Millisecond
+	//defaultMaintainInterval 未设置 IdleTimeout 时后台维护协程的默认运行间隔
+	defaultMaintainInterval = time.Second
+)
+
+//DrainContext 优雅关闭连接池
+//立即拒绝新的 Get 请求并关闭空闲连接 然后等待所有已借出的连接归还(归还时即被关闭)
+//ctx 结束前仍有连接未归还或空闲连接未关闭完成则返回 ErrDrainTimeout
+func (c *connectionPool) DrainContext(ctx context.Context) error {
+	err := c.shutdown(ctx)
+	if errors.Is(err, ErrDrainTimeout) {
+		return err
+	}
+	drainTicker := c.clock.NewTicker(drainPollInterval)
+	defer drainTicker.Stop()
+	for c.ActiveLen() > 0 {
+		select {
+		case <-ctx.Done():
+			c.logger.Warnf("simpleConnPool: drain timed out with %d connections still borrowed", c.ActiveLen())
+			return ErrDrainTimeout
+		case <-drainTicker.C():
+		}
+	}
+	return err
+}
+
+//ShutdownContext 在 ctx 结束前与 DrainContext 相同 优雅关闭连接池并等待借出的连接归还
+//ctx 结束时仍未归还的连接被强制关闭 返回 ErrForcedShutdown 与关闭错误的合并 之后再归还这些连接将返回 ErrUnknownConnection
+func (c *connectionPool) ShutdownContext(ctx context.Context) error {
+	err := c.DrainContext(ctx)
+	if !errors.Is(err, ErrDrainTimeout) {
+		return err
+	}
+	n, closeErr := c.closeBorrowed()
+	if n == 0 {
+		return err
+	}
+	c.logger.Warnf("simpleConnPool: shutdown deadline exceeded, force closed %d borrowed connections", n)
+	return errors.Join(ErrForcedShutdown, closeErr)
+}
+
+//closeBorrowed 关闭所有仍被借出的连接 返回关闭的连接数与关闭错误的合并
+//与 Put 并发时通过 release 保证每个连接只被归还或关闭一次
+func (c *connectionPool) closeBorrowed() (int, error) {
+	var records []*borrowRecord
+	c.borrowedConns.Range(func(v any) bool {
+		records = append(records, v.(*borrowRecord))
+		return true
+	})
+	n := 0
+	var errs []error
+	for _, rec := range records {
+		idleC, ok := c.release(rec.idleC.connection)
+		if !ok {
+			continue
+		}
+		n++
+		var err error
+		if idleC.uncounted {
+			err = c.closeUncounted(idleC)
+		} else {
+			atomic.AddInt32(&c.activeConn, -1)
+			err = c.closeConn(idleC)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return n, errors.Join(errs...)
+}
+
+//IsClosed 连接池是否已经关闭
+func (c *connectionPool) IsClosed() bool {
+	return c.isClosed()
+}
+
+//isClosed 连接池是否已经关闭
+func (c *connectionPool) isClosed() bool {
+	return atomic.LoadInt32(&c.closed) == 1
+}
+
+//maintain 后台维护协程 连接池关闭时退出
+func (c *connectionPool) maintain(interval, healthInterval time.Duration) {
+	maintainTicker := c.clock.NewTicker(interval)
+	defer maintainTicker.Stop()
+	var healthC <-chan time.Time
+	if c.healthCheck != nil {
+		healthTicker := c.clock.NewTicker(healthInterval)
+		defer healthTicker.Stop()
+		healthC = healthTicker.C()
+	}
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-maintainTicker.C():
+			c.reapIdle()
+			c.trimIdle(interval)
+			c.fillIdle()
+			c.detectLeaks()
+		case <-healthC:
+			c.checkIdle()
+			//补充被关闭的连接
+			c.fillIdle()
+		}
+	}
+}
+
+//checkIdle 对当前所有空闲连接执行存活检测 关闭检测失败的连接
+//每次只从空闲队列中取出正在检测的一个连接 避免被同时借出 其余连接在检测期间仍可借出 检测通过的连接重新放回
+func (c *connectionPool) checkIdle() {
+	c.idleMu.Lock()
+	idle := append([]*idleConn(nil), c.idle...)
+	c.idleMu.Unlock()
+
+	dead := 0
+	for _, idleC := range idle {
+		if !c.takeIdle(idleC) {
+			continue
+		}
+		if err := c.healthCheck(idleC.connection); err != nil {
+			dead++
+			c.logger.Debugf("simpleConnPool: health check failed, closing idle connection%s: %v", metaSuffix(idleC.connection), err)
+			_ = c.closeConn(idleC)
+			continue
+		}
+		_ = c.recycle(idleC)
+	}
+	if dead > 0 {
+		c.logger.Warnf("simpleConnPool: health check closed %d dead idle connections", dead)
+	}
+}
+
+//takeIdle 从空闲队列中取出 idleC 不在队列中 例如已被借出或关闭时返回 false
+func (c *connectionPool) takeIdle(idleC *idleConn) bool {
+	c.idleMu.Lock()
+	defer c.idleMu.Unlock()
+	for i, v := range c.idle {
+		if v == idleC {
+			copy(c.idle[i:], c.idle[i+1:])
+			c.idle[len(c.idle)-1] = nil
+			c.idle = c.idle[:len(c.idle)-1]
+			return true
+		}
+	}
+	return false
+}
+
+//reapIdle 扫描一遍空闲队列 关闭超过最大空闲时间或最大存活时间的连接
+//关闭后空闲连接数会少于 idleFloor 时 先创建新连接再关闭旧连接 无法创建时保留旧连接
+func (c *connectionPool) reapIdle() {
+	var expired, stale []*idleConn
+	c.idleMu.Lock()
+	remain := int32(len(c.idle))
+	kept := c.idle[:0]
+	for _, idleC := range c.idle {
+		if c.idleTimeoutExceeded(idleC) || c.lifetimeExceeded(idleC) {
+			if remain > c.idleFloor {
+				remain--
+				expired = append(expired, idleC)
+			} else {
+				stale = append(stale, idleC)
+			}
+			continue
+		}
+		kept = append(kept, idleC)
+	}
+	for i := len(kept); i < len(c.idle); i++ {
+		c.idle[i] = nil
+	}
+	c.idle = kept
+	c.idleMu.Unlock()
+
+	if len(expired) > 0 {
+		for range expired {
+			c.emit(EventExpire)
+		}
+		_ = c.closeIdle(expired)
+		c.logger.Debugf("simpleConnPool: reaped %d idle connections", len(expired))
+	}
+	for _, idleC := range stale {
+		c.replaceIdle(idleC)
+	}
+}
+
+//trimIdle 开启 IdleOverflow 时关闭超出 maxIdle 且空闲时间达到 idleFor 的连接 优先关闭最早变为空闲的连接
+//突发流量期间被反复借出的连接空闲时间很短 不会被关闭 initialCap 大于 maxIdle 时至少保留 initialCap 个 避免回收后又被补足
+func (c *connectionPool) trimIdle(idleFor time.Duration) {
+	if !c.idleOverflow {
+		return
+	}
+	now := c.clock.Now()
+	var excess []*idleConn
+	c.idleMu.Lock()
+	target := c.maxIdle
+	if c.initialCap > target {
+		target = c.initialCap
+	}
+	if over := len(c.idle) - int(target); over > 0 {
+		sort.SliceStable(c.idle, func(i, j int) bool {
+			return c.idle[i].lastActiveTime.Before(c.idle[j].lastActiveTime)
+		})
+		kept := c.idle[:0]
+		for _, idleC := range c.idle {
+			//按 lastActiveTime 排序 遇到空闲时间不足的连接后 之后的连接都不会被关闭
+			if len(excess) < over && !idleC.pinned && now.Sub(idleC.lastActiveTime) >= idleFor {
+				excess = append(excess, idleC)
+				continue
+			}
+			kept = append(kept, idleC)
+		}
+		for i := len(kept); i < len(c.idle); i++ {
+			c.idle[i] = nil
+		}
+		c.idle = kept
+	}
+	c.idleMu.Unlock()
+
+	if len(excess) > 0 {
+		_ = c.closeIdle(excess)
+		c.logger.Debugf("simpleConnPool: trimmed %d idle connections above MaxIdle", len(excess))
+	}
+}
+
+//replaceIdle 创建一个新连接替换已失效的空闲连接 无法创建时将旧连接放回 下一次回收时重试
+func (c *connectionPool) replaceIdle(old *idleConn) {
+	if c.reserveConn() {
+		if fresh, err := c.createConn(context.Background()); err == nil {
+			c.emit(EventExpire)
+			_ = c.closeConn(old)
+			_ = c.recycle(fresh)
+			return
+		}
+	}
+	_ = c.recycle(old)
+}
+
+//detectLeaks 对借出时间超过 leakThreshold 的连接输出一次泄漏警告
+func (c *connectionPool) detectLeaks() {
+	if c.leakThreshold <= 0 {
+		return
+	}
+	type leak struct {
+		held  time.Duration
+		stack []byte
+		conn  any
+	}
+	var leaks []leak
+	now := c.clock.Now()
+	c.borrowedConns.Range(func(v any) bool {
+		rec := v.(*borrowRecord)
+		if held := now.Sub(rec.at); held > c.leakThreshold && atomic.CompareAndSwapInt32(&rec.reported, 0, 1) {
+			leaks = append(leaks, leak{held: held, stack: rec.stack, conn: rec.idleC.connection})
+		}
+		return true
+	})
+
+	for _, l := range leaks {
+		if l.stack != nil {
+			c.logger.Warnf("simpleConnPool: connection%s borrowed %v ago has not been returned, borrowed at:\n%s", metaSuffix(l.conn), l.held, l.stack)
+			continue
+		}
+		c.logger.Warnf("simpleConnPool: connection%s borrowed %v ago has not been returned", metaSuffix(l.conn), l.held)
+	}
+}
+
+//fillIdle 空闲连接数低于 minIdle 或存活连接数低于 InitialCap 时创建新的连接补充 不会超过最大连接数
+func (c *connectionPool) fillIdle() {
+	need := c.minIdle - int32(c.IdleLen())
+	if short := c.initialCap - atomic.LoadInt32(&c.openingConn); short > need {
+		need = short
+	}
+	//固定连接被关闭后补足 即使其他连接使存活连接数不少于 initialCap
+	if short := c.pinLimit() - atomic.LoadInt32(&c.pinnedConn); c.pinInitial && short > need {
+		need = short
+	}
+	//新建的连接放入空闲队列 不超过空闲队列的剩余容量 避免 SetMaxIdle 调小后反复创建又关闭
+	c.idleMu.Lock()
+	room := c.idleLimit() - int32(len(c.idle))
+	c.idleMu.Unlock()
+	if need > room {
+		need = room
+	}
+	for i := int32(0); i < need && !c.isClosed(); i++ {
+		if !c.reserveConn() {
+			return
+		}
+		idleC, err := c.createConn(context.Background())
+		if err != nil {
+			return
+		}
+		c.pin(idleC)
+		//有等待中的请求时优先交给等待的请求
+		_ = c.recycle(idleC)
+	}
+}
+
+//popIdle 从空闲队列取出一个连接 FIFO 取队头 LIFO 取队尾 没有空闲连接时返回 nil
+func (c *connectionPool) popIdle() *idleConn {
+	c.idleMu.Lock()
+	defer c.idleMu.Unlock()
+	if len(c.idle) == 0 {
+		return nil
+	}
+	if c.lifo {
+		last := len(c.idle) - 1
+		idleC := c.idle[last]
+		c.idle[last] = nil
+		c.idle = c.idle[:last]
+		return idleC
+	}
+	idleC := c.idle[0]
+	c.idle[0] = nil
+	//取出最后一个连接时回到底层数组的起点 避免只有一个空闲连接反复借出归还时每次 append 都重新分配
+	if len(c.idle) == 1 {
+		c.idle = c.idle[:0]
+		return idleC
+	}
+	c.idle = c.idle[1:]
+	return idleC
+}
+
+//pushIdle 将连接放入空闲队列队尾 连接池已关闭或不缓存空闲连接时返回 false
+//空闲队列已满时挤出队头的非固定连接并返回 该连接比 idleC 更新或全部为固定连接时返回 false
+func (c *connectionPool) pushIdle(idleC *idleConn) (*idleConn, bool) {
+	c.idleMu.Lock()
+	defer c.idleMu.Unlock()
+	limit := c.idleLimit()
+	if c.isClosed() || limit <= 0 {
+		return nil, false
+	}
+	if int32(len(c.idle)) < limit {
+		c.idle = append(c.idle, idleC)
+		return nil, true
+	}
+	i := c.stalestIdle()
+	if i < 0 {
+		return nil, false
+	}
+	evicted := c.idle[i]
+	if !evicted.lastActiveTime.Before(idleC.lastActiveTime) {
+		return nil, false
+	}
+	//队头前 i 个固定连接后移一位 填补被挤出的位置
+	copy(c.idle[1:i+1], c.idle[:i])
+	c.idle[0] = nil
+	c.idle = append(c.idle[1:], idleC)
+	return evicted, true
+}
+
+//idleLimit 返回空闲队列最多保存的连接数 调用方需持有 idleMu
+//开启 IdleOverflow 时最多保存 maxActiveConn 个连接 maxActiveConn 小于等于0时不限制 MaxIdle 为0表示不缓存空闲连接 IdleOverflow 也不会改变
+func (c *connectionPool) idleLimit() int32 {
+	if !c.idleOverflow || c.maxIdle <= 0 {
+		return c.maxIdle
+	}
+	if limit := atomic.LoadInt32(&c.maxActiveConn); limit > 0 {
+		return limit
+	}
+	return math.MaxInt32
+}
+
+//stalestIdle 返回空闲队列中最早放入的非固定连接下标 调用方需持有 idleMu 全部为固定连接时返回 -1
+//连接按归还的先后追加到队尾 队头即 lastActiveTime 最早的连接 只需跳过队头的固定连接 最多检查 pinnedConn+1 个连接
+func (c *connectionPool) stalestIdle() int {
+	for i, idleC := range c.idle {
+		if !idleC.pinned {
+			return i
+		}
+	}
+	return -1
+}
+
+//closeIdle 关闭一组已从空闲队列中移除的连接 返回第一个关闭错误
+func (c *connectionPool) closeIdle(idle []*idleConn) error {
+	var err error
+	for _, idleC := range idle {
+		if closeErr := c.closeConn(idleC); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+//SetMaxCap 动态调整最大并发存活连接数 n 小于等于0表示不限制
+//调大后新的请求可以立即创建连接 等待中的请求也会被分配新连接 调小时不会关闭已存在的连接 只是不再创建超出新上限的连接
+//n 小于当前 MaxIdle 或者设置了 BurstCap 而 n 不小于 BurstCap 时返回 InvalidCapSet 连接池已关闭时返回 PoolClosed
+func (c *connectionPool) SetMaxCap(n int32) error {
+	return c.setLimits(func(l *limits) { l.maxCap = n })
+}
+
+//checkMaxCap 检查 n 能否作为新的最大连接数 不做修改
+func (c *connectionPool) checkMaxCap(n int32) error {
+	return c.checkLimits(func(l *limits) { l.maxCap = n })
+}
+
+//SetMaxIdle 动态调整最大空闲连接数 调小时关闭超出的空闲连接
+//与 Config.Check 相同 n 小于0 大于 MaxCap 开启 NoIdle 时大于0或开启 IdleOverflow 时为0 返回 InvalidCapSet 连接池已关闭时返回 PoolClosed
+func (c *connectionPool) SetMaxIdle(n int32) error {
+	return c.setLimits(func(l *limits) { l.maxIdle = n })
+}
+
+//checkMaxIdle 检查 n 能否作为新的最大空闲连接数 不做修改
+func (c *connectionPool) checkMaxIdle(n int32) error {
+	return c.checkLimits(func(l *limits) { l.maxIdle = n })
+}
+
+//limits 可以在运行时修改的连接数上限
+type limits struct {
+	maxCap   int32 //最大连接数
+	maxIdle  int32 //最大空闲连接数
+	burstCap int32 //包含突发连接在内的最大连接数
+}
+
+//configLimits 返回 cfg 中的连接数上限
+func configLimits(cfg *Config) limits {
+	return limits{maxCap: cfg.MaxCap, maxIdle: cfg.MaxIdle, burstCap: cfg.BurstCap}
+}
+
+//loadLimits 返回当前的连接数上限 调用方持有 idleMu
+func (c *connectionPool) loadLimits() limits {
+	return limits{
+		maxCap:   atomic.LoadInt32(&c.maxActiveConn),
+		maxIdle:  c.maxIdle,
+		burstCap: atomic.LoadInt32(&c.burstCap),
+	}
+}
+
+//validLimits 按 Config.Check 的规则检查 l NoIdle 与 IdleOverflow 使用创建连接池时的配置
+func (c *connectionPool) validLimits(l limits) error {
+	if l.maxIdle < 0 || l.maxCap > 0 && l.maxIdle > l.maxCap || c.noIdle && l.maxIdle > 0 || c.idleOverflow && l.maxIdle == 0 {
+		return InvalidCapSet
+	}
+	if l.burstCap > 0 && (l.maxCap <= 0 || l.burstCap <= l.maxCap) {
+		return InvalidCapSet
+	}
+	return nil
+}
+
+//checkLimits 检查以 update 修改后的连接数上限是否合法 不做修改
+func (c *connectionPool) checkLimits(update func(*limits)) error {
+	if c.isClosed() {
+		return PoolClosed
+	}
+	c.idleMu.Lock()
+	l := c.loadLimits()
+	c.idleMu.Unlock()
+	update(&l)
+	return c.validLimits(l)
+}
+
+//setLimits 以 update 修改连接数上限 修改后的上限不合法时返回 InvalidCapSet 且不修改任何一项
+//检查与修改在同一次持有 idleMu 时完成 调小 maxIdle 时关闭超出的空闲连接 调大上限时为等待中的请求创建连接
+func (c *connectionPool) setLimits(update func(*limits)) error {
+	if c.isClosed() {
+		return PoolClosed
+	}
+	c.idleMu.Lock()
+	old := c.loadLimits()
+	l := old
+	update(&l)
+	if err := c.validLimits(l); err != nil {
+		c.idleMu.Unlock()
+		return err
+	}
+	atomic.StoreInt32(&c.maxActiveConn, l.maxCap)
+	atomic.StoreInt32(&c.burstCap, l.burstCap)
+	c.maxIdle = l.maxIdle
+	excess := c.excessIdle(l.maxIdle)
+	c.idleMu.Unlock()
+	//只有调大或取消限制时才有新的连接数可以分配给等待中的请求
+	if old.maxCap > 0 && (l.maxCap <= 0 || l.maxCap > old.maxCap) || l.burstCap > old.burstCap {
+		c.replaceForWaiters()
+	}
+	return c.closeIdle(excess)
+}
+
+//excessIdle 从空闲队列中移除超出 n 个的连接并返回 调用方持有 idleMu
+func (c *connectionPool) excessIdle(n int32) []*idleConn {
+	var excess []*idleConn
+	if over := len(c.idle) - int(n); over > 0 {
+		//从队头即最早变为空闲的连接开始关闭 优先关闭非固定连接 非固定连接不足时才关闭固定连接
+		//只移除连接不重新排序 保留的连接仍按原来的 FIFO/LIFO 顺序借出
+		unpinned := 0
+		for _, idleC := range c.idle {
+			if !idleC.pinned {
+				unpinned++
+			}
+		}
+		dropUnpinned := over
+		if unpinned < over {
+			dropUnpinned = unpinned
+		}
+		dropPinned := over - dropUnpinned
+		kept := c.idle[:0]
+		for _, idleC := range c.idle {
+			if !idleC.pinned && dropUnpinned > 0 {
+				dropUnpinned--
+				excess = append(excess, idleC)
+				continue
+			}
+			if idleC.pinned && dropPinned > 0 {
+				dropPinned--
+				excess = append(excess, idleC)
+				continue
+			}
+			kept = append(kept, idleC)
+		}
+		for i := len(kept); i < len(c.idle); i++ {
+			c.idle[i] = nil
+		}
+		c.idle = kept
+	}
+	return excess
+}
+
+//DrainIdle 关闭当前所有空闲连接并释放其占用的连接数 不影响已借出的连接 连接池保持可用
+//之后的 Get 按需创建新连接 后台维护协程按 MinIdle 与 InitialCap 补足 用于后端重新部署后强制重新建立连接
+//连接池已关闭时返回 PoolClosed 否则返回第一个关闭错误
+func (c *connectionPool) DrainIdle() error {
+	if c.isClosed() {
+		return PoolClosed
+	}
+	c.idleMu.Lock()
+	idle := c.idle
+	c.idle = make([]*idleConn, 0, cap(idle))
+	c.idleMu.Unlock()
+
+	err := c.closeIdle(idle)
+	c.logger.Debugf("simpleConnPool: drained %d idle connections", len(idle))
+	//空出的连接数交给等待中的请求
+	c.replaceForWaiters()
+	return err
+}
+
+//fillWaiters 在有空余连接数时为等待中的请求创建连接
+//创建失败时把错误交给队头的请求 后端故障时等待中的请求逐个返回错误 而不是一直等待或反复重试
+//释放的连接数已经由 createConn 交给其余等待中的请求
+func (c *connectionPool) fillWaiters() {
+	for c.waitingLen() > 0 && !c.isClosed() {
+		burst, ok := c.reserveSlot()
+		if !ok {
+			return
+		}
+		idleC, err := c.createConn(context.Background())
+		if err != nil {
+			c.failWaiter(err)
+			return
+		}
+		if burst {
+			c.markBurst(idleC)
+			atomic.AddInt64(&c.counters.totalBursts, 1)
+		}
+		_ = c.recycle(idleC)
+	}
+}
+
+//failWaiter 将队头的请求移出等待队列 并把 err 交给该请求
+func (c *connectionPool) failWaiter(err error) {
+	c.waitMu.Lock()
+	defer c.waitMu.Unlock()
+	front := c.waiters.Front()
+	if front == nil {
+		return
+	}
+	req := c.waiters.Remove(front).(*connReq)
+	req.elem = nil
+	<-c.waitSlots
+	req.err = err
+	//缓冲为1 不会阻塞
+	req.idleConn <- nil
 }
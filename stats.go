@@ -0,0 +1,176 @@
+package simpleConnPool
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+//Stats 连接池运行状态统计
+type Stats struct {
+	IdleCount       int32 //当前空闲连接数
+	ActiveCount     int32 //当前已借出未归还的连接数
+	PeakActive      int32 //创建连接池或上一次 ResetPeak 以来 ActiveCount 达到过的最大值 ShardedPool 中为各分片峰值之和
+	OpeningConn     int32 //当前正在运行的连接数
+	UncountedConn   int32 //当前借出的不计入 MaxCap 的连接数 不包含在 ActiveCount 与 OpeningConn 中
+	BurstConn       int32 //当前存活的超出 MaxCap 的突发连接数 包含在 ActiveCount 与 OpeningConn 中
+	WaitingRequests int32 //当前等待获取连接的请求数
+	Waiters         int32 //当前阻塞在等待队列中的 goroutine 数 与 Waiters 返回值相同 包括已被分配连接但尚未返回的请求
+
+	TotalGets          int64         //累计成功获取连接次数
+	TotalTimeouts      int64         //累计等待连接超时次数
+	TotalFactoryErrors int64         //累计创建连接失败次数
+	TotalWaits         int64         //累计进入等待队列的次数
+	TotalBursts        int64         //累计创建突发连接的次数 持续增长说明 MaxCap 偏小
+	TotalWaitDuration  time.Duration //累计在等待队列中等待的时间
+	WaitLatency        WaitHistogram //在等待队列中等待时间的分布
+	DroppedEvents      int64         //累计因事件 channel 已满而丢弃的事件数
+
+	Circuit CircuitState //连接创建熔断器状态 未启用时为 CircuitClosed
+
+	Backends []BackendStats //多后端连接池中每个后端的状态 其他连接池为空
+}
+
+//poolCounters 连接池累计计数器 仅通过原子操作读写
+//必须作为 connectionPool 的第一个字段 以保证在 32 位平台上 64 位原子操作的对齐
+type poolCounters struct {
+	totalGets          int64
+	totalTimeouts      int64
+	totalFactoryErrors int64
+	totalWaits         int64
+	totalBursts        int64
+	totalWaitDuration  int64 //纳秒
+	droppedEvents      int64
+	waitBuckets        [len(WaitBuckets) + 1]int64
+}
+
+//WaitBuckets 等待时间分布的桶上界 最后还有一个 +Inf 桶
+var WaitBuckets = [...]time.Duration{time.Millisecond, 10 * time.Millisecond, 100 * time.Millisecond, time.Second}
+
+//WaitHistogram 在等待队列中等待时间的分布 只统计进入等待队列的 Get 命中空闲连接的 Get 不计入
+//Buckets[i] 为等待时间不超过 WaitBuckets[i] 且超过前一个上界的次数 Buckets[len(WaitBuckets)] 为超过最大上界的次数
+type WaitHistogram struct {
+	Buckets [len(WaitBuckets) + 1]int64 //每个桶的次数 非累积
+	Count   int64                       //总次数 与 TotalWaits 相同
+	Sum     time.Duration               //总等待时间 与 TotalWaitDuration 相同
+}
+
+//recordWait 记录一次进入等待队列的等待时间
+func (pc *poolCounters) recordWait(d time.Duration) {
+	atomic.AddInt64(&pc.totalWaits, 1)
+	atomic.AddInt64(&pc.totalWaitDuration, int64(d))
+	i := 0
+	for i < len(WaitBuckets) && d > WaitBuckets[i] {
+		i++
+	}
+	atomic.AddInt64(&pc.waitBuckets[i], 1)
+}
+
+//Stats 返回连接池当前的运行状态
+func (c *connectionPool) Stats() Stats {
+	stats := Stats{
+		IdleCount:          int32(c.IdleLen()),
+		ActiveCount:        atomic.LoadInt32(&c.activeConn),
+		PeakActive:         atomic.LoadInt32(&c.maxActiveSeen),
+		OpeningConn:        atomic.LoadInt32(&c.openingConn),
+		UncountedConn:      atomic.LoadInt32(&c.uncountedConn),
+		BurstConn:          atomic.LoadInt32(&c.burstConn),
+		WaitingRequests:    int32(c.waitingLen()),
+		Waiters:            atomic.LoadInt32(&c.waiting),
+		TotalGets:          atomic.LoadInt64(&c.counters.totalGets),
+		TotalTimeouts:      atomic.LoadInt64(&c.counters.totalTimeouts),
+		TotalFactoryErrors: atomic.LoadInt64(&c.counters.totalFactoryErrors),
+		TotalWaits:         atomic.LoadInt64(&c.counters.totalWaits),
+		TotalBursts:        atomic.LoadInt64(&c.counters.totalBursts),
+		TotalWaitDuration:  time.Duration(atomic.LoadInt64(&c.counters.totalWaitDuration)),
+		DroppedEvents:      atomic.LoadInt64(&c.counters.droppedEvents),
+		Circuit:            c.breaker.currentState(),
+	}
+	for i := range stats.WaitLatency.Buckets {
+		stats.WaitLatency.Buckets[i] = atomic.LoadInt64(&c.counters.waitBuckets[i])
+	}
+	stats.WaitLatency.Count = stats.TotalWaits
+	stats.WaitLatency.Sum = stats.TotalWaitDuration
+	return stats
+}
+
+//StatsSnapshotAndReset 返回当前的运行状态 并将累计计数器清零 用于按周期上报增量
+//每个计数器通过原子交换清零 并发的 Get 不会丢失计数 当前连接数等状态不受影响
+func (c *connectionPool) StatsSnapshotAndReset() Stats {
+	stats := c.Stats()
+	stats.TotalGets = atomic.SwapInt64(&c.counters.totalGets, 0)
+	stats.TotalTimeouts = atomic.SwapInt64(&c.counters.totalTimeouts, 0)
+	stats.TotalFactoryErrors = atomic.SwapInt64(&c.counters.totalFactoryErrors, 0)
+	stats.TotalWaits = atomic.SwapInt64(&c.counters.totalWaits, 0)
+	stats.TotalBursts = atomic.SwapInt64(&c.counters.totalBursts, 0)
+	stats.TotalWaitDuration = time.Duration(atomic.SwapInt64(&c.counters.totalWaitDuration, 0))
+	stats.DroppedEvents = atomic.SwapInt64(&c.counters.droppedEvents, 0)
+	for i := range stats.WaitLatency.Buckets {
+		stats.WaitLatency.Buckets[i] = atomic.SwapInt64(&c.counters.waitBuckets[i], 0)
+	}
+	stats.WaitLatency.Count = stats.TotalWaits
+	stats.WaitLatency.Sum = stats.TotalWaitDuration
+	return stats
+}
+
+//String 返回连接池当前状态的单行描述 可以并发调用
+func (c *connectionPool) String() string {
+	return fmt.Sprintf("simpleConnPool{idle: %d, active: %d, opening: %d, maxCap: %d, waiting: %d, closed: %t}",
+		c.IdleLen(), c.ActiveLen(), atomic.LoadInt32(&c.openingConn), atomic.LoadInt32(&c.maxActiveConn), c.waitingLen(), c.isClosed())
+}
+
+//Len 返回当前存活的连接数 即空闲连接数与已借出连接数之和
+func (c *connectionPool) Len() int {
+	return c.IdleLen() + c.ActiveLen()
+}
+
+//IdleLen 返回当前空闲连接数
+func (c *connectionPool) IdleLen() int {
+	c.idleMu.Lock()
+	defer c.idleMu.Unlock()
+	return len(c.idle)
+}
+
+//ActiveLen 返回当前已借出未归还的连接数
+func (c *connectionPool) ActiveLen() int {
+	return int(atomic.LoadInt32(&c.activeConn))
+}
+
+//notePeak 借出连接使 activeConn 增加到 active 后更新峰值 通过 CAS 循环保证并发时不会把峰值改小
+func (c *connectionPool) notePeak(active int32) {
+	for {
+		peak := atomic.LoadInt32(&c.maxActiveSeen)
+		if active <= peak || atomic.CompareAndSwapInt32(&c.maxActiveSeen, peak, active) {
+			return
+		}
+	}
+}
+
+//ResetPeak 将 Stats 中的 PeakActive 重置为当前已借出的连接数 开始新的统计窗口
+func (c *connectionPool) ResetPeak() {
+	atomic.StoreInt32(&c.maxActiveSeen, atomic.LoadInt32(&c.activeConn))
+}
+
+//Waiters 返回当前阻塞在等待队列中的 goroutine 数 可用于在连接池饱和时拒绝上游请求
+func (c *connectionPool) Waiters() int {
+	return int(atomic.LoadInt32(&c.waiting))
+}
+
+//BorrowInfo 一个已借出未归还的连接
+type BorrowInfo struct {
+	Age  time.Duration  //借出至今的时间
+	Meta map[string]any //连接的元数据 连接未实现 WithMeta 时为 nil
+}
+
+//Borrowed 返回当前所有已借出未归还连接的快照 包括不计入 MaxCap 的连接 可以并发调用
+//返回值是副本 不持有连接池的锁 可以在 HTTP 处理函数中调用
+func (c *connectionPool) Borrowed() []BorrowInfo {
+	now := c.clock.Now()
+	var borrowed []BorrowInfo
+	c.borrowedConns.Range(func(v any) bool {
+		rec := v.(*borrowRecord)
+		borrowed = append(borrowed, BorrowInfo{Age: now.Sub(rec.at), Meta: connMeta(rec.idleC.connection)})
+		return true
+	})
+	return borrowed
+}
@@ -0,0 +1,77 @@
+package simpleConnPool
+
+import (
+	"context"
+	"time"
+)
+
+/*
+====== 获取连接过程的追踪回调 =======
+*/
+
+//ConnSource 借出连接的来源
+type ConnSource int32
+
+const (
+	SourceNone    ConnSource = iota //未获取到连接
+	SourceIdle                      //复用空闲队列中的连接
+	SourceCreated                   //新创建的连接
+	SourceWaited                    //等待其他请求归还的连接
+)
+
+//String 返回连接来源名称
+func (s ConnSource) String() string {
+	switch s {
+	case SourceIdle:
+		return "idle"
+	case SourceCreated:
+		return "created"
+	case SourceWaited:
+		return "waited"
+	}
+	return "none"
+}
+
+//GetInfo 一次获取连接的过程信息
+type GetInfo struct {
+	Source   ConnSource    //连接来源 获取失败时为 SourceNone
+	FromIdle bool          //是否复用了空闲队列中的连接 即 Source 为 SourceIdle
+	Created  bool          //是否为本次获取新创建的连接 即 Source 为 SourceCreated
+	Waited   time.Duration //在等待队列中等待的时间
+	Err      error         //获取失败时的错误
+}
+
+//setSource 记录连接来源 info 为空时不做任何事
+func (info *GetInfo) setSource(s ConnSource) {
+	if info != nil {
+		info.Source = s
+		info.FromIdle = s == SourceIdle
+		info.Created = s == SourceCreated
+	}
+}
+
+//addWait 累加等待时间 info 为空时不做任何事
+func (info *GetInfo) addWait(d time.Duration) {
+	if info != nil {
+		info.Waited += d
+	}
+}
+
+//GetTrace 获取连接过程的回调 通过 WithGetTrace 放入 ctx 后传给 GetContext
+type GetTrace struct {
+	GotConn func(info GetInfo) //获取连接结束时调用 无论成功或失败
+}
+
+//getTraceKey GetTrace 在 context 中的 key
+type getTraceKey struct{}
+
+//WithGetTrace 返回携带 trace 的 ctx 使用该 ctx 调用 GetContext 时会触发 trace 中的回调
+func WithGetTrace(ctx context.Context, trace *GetTrace) context.Context {
+	return context.WithValue(ctx, getTraceKey{}, trace)
+}
+
+//contextGetTrace 返回 ctx 携带的 GetTrace 没有时返回 nil
+func contextGetTrace(ctx context.Context) *GetTrace {
+	trace, _ := ctx.Value(getTraceKey{}).(*GetTrace)
+	return trace
+}
@@ -0,0 +1,125 @@
+package tracing
+
+import (
+	"context"
+	"simpleConnPool"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+/*
+====== 连接池 OpenTelemetry 追踪 =======
+*/
+
+const (
+	//instrumentationName 未指定 Tracer 时使用的 instrumentation 名称
+	instrumentationName = "simpleConnPool/tracing"
+	//spanName 获取连接的 span 名称
+	spanName = "simpleConnPool.Get"
+	//tryGetSpanName TryGet 的 span 名称
+	tryGetSpanName = "simpleConnPool.TryGet"
+	//getManySpanName GetMany 的 span 名称
+	getManySpanName = "simpleConnPool.GetMany"
+
+	AttrConnSource   = attribute.Key("pool.conn.source")      //连接来源 idle created waited none
+	AttrWaitDuration = attribute.Key("pool.wait.duration_ms") //在等待队列中等待的毫秒数
+	AttrGetCount     = attribute.Key("pool.get.count")        //GetMany 请求的连接数
+)
+
+//tracedPool 为获取连接的方法创建 span 的连接池 其余方法直接调用内部连接池
+type tracedPool struct {
+	simpleConnPool.Pool
+	tracer trace.Tracer
+}
+
+//NewTracedPool 包装连接池 Get GetContext GetWithPriority GetWithInfo TryGet 与 GetMany 会在 ctx 中的 span 下创建子 span
+//获取单个连接时记录连接是复用空闲连接 新创建还是等待得到 以及等待的时间 tracer 为空时使用全局 TracerProvider
+//TryGet 没有 ctx 连接池也不提供它的过程信息 span 只记录错误 GetMany 的 span 记录请求的连接数
+func NewTracedPool(p simpleConnPool.Pool, tracer trace.Tracer) simpleConnPool.Pool {
+	if tracer == nil {
+		tracer = otel.GetTracerProvider().Tracer(instrumentationName)
+	}
+	return &tracedPool{Pool: p, tracer: tracer}
+}
+
+//Get 向连接池中获取一个连接
+func (p *tracedPool) Get() (any, error) {
+	return p.GetContext(context.Background())
+}
+
+//GetContext 向连接池中获取一个连接 获取过程记录在子 span 中 span 在所有返回路径上结束
+func (p *tracedPool) GetContext(ctx context.Context) (any, error) {
+	return p.traceGet(ctx, p.Pool.GetContext)
+}
+
+//GetWithPriority 向连接池中获取一个连接 需要等待时按 priority 排队 获取过程记录在子 span 中
+func (p *tracedPool) GetWithPriority(ctx context.Context, priority int) (any, error) {
+	return p.traceGet(ctx, func(ctx context.Context) (any, error) {
+		return p.Pool.GetWithPriority(ctx, priority)
+	})
+}
+
+//GetWithInfo 向连接池中获取一个连接 同时返回过程信息 获取过程记录在子 span 中
+func (p *tracedPool) GetWithInfo(ctx context.Context) (any, simpleConnPool.GetInfo, error) {
+	ctx, span := p.tracer.Start(ctx, spanName)
+	defer span.End()
+
+	conn, info, err := p.Pool.GetWithInfo(ctx)
+	endGet(span, info, err)
+	return conn, info, err
+}
+
+//TryGet 不等待地获取一个连接 获取过程记录在子 span 中
+func (p *tracedPool) TryGet() (any, error) {
+	_, span := p.tracer.Start(context.Background(), tryGetSpanName)
+	defer span.End()
+
+	conn, err := p.Pool.TryGet()
+	recordError(span, err)
+	return conn, err
+}
+
+//GetMany 一次获取 n 个连接 全部获取成功或全部失败 获取过程记录在子 span 中
+func (p *tracedPool) GetMany(ctx context.Context, n int) ([]any, error) {
+	ctx, span := p.tracer.Start(ctx, getManySpanName)
+	defer span.End()
+
+	conns, err := p.Pool.GetMany(ctx, n)
+	span.SetAttributes(AttrGetCount.Int(n))
+	recordError(span, err)
+	return conns, err
+}
+
+//traceGet 在子 span 中调用 get 通过 GetTrace 取得过程信息记录到 span 上 span 在所有返回路径上结束
+func (p *tracedPool) traceGet(ctx context.Context, get func(ctx context.Context) (any, error)) (any, error) {
+	ctx, span := p.tracer.Start(ctx, spanName)
+	defer span.End()
+
+	var info simpleConnPool.GetInfo
+	ctx = simpleConnPool.WithGetTrace(ctx, &simpleConnPool.GetTrace{
+		GotConn: func(i simpleConnPool.GetInfo) { info = i },
+	})
+	conn, err := get(ctx)
+	endGet(span, info, err)
+	return conn, err
+}
+
+//endGet 记录获取单个连接的来源 等待时间与错误
+func endGet(span trace.Span, info simpleConnPool.GetInfo, err error) {
+	span.SetAttributes(
+		AttrConnSource.String(info.Source.String()),
+		AttrWaitDuration.Float64(float64(info.Waited)/1e6),
+	)
+	recordError(span, err)
+}
+
+//recordError err 不为空时记录到 span 上并将 span 标记为失败
+func recordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
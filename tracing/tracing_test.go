@@ -0,0 +1,151 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"simpleConnPool"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracedPool(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(context.Background())
+
+	inner, err := simpleConnPool.NewPool(&simpleConnPool.Config{
+		MaxCap:      1,
+		MaxIdle:     1,
+		Factory:     func() (interface{}, error) { return new(int), nil },
+		Close:       func(interface{}) error { return nil },
+		WaitTimeout: 50 * time.Millisecond,
+		WaitQueue:   1,
+	})
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	p := NewTracedPool(inner, provider.Tracer("test"))
+	defer p.Shutdown()
+
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	_ = p.Put(conn)
+	held, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	time.AfterFunc(10*time.Millisecond, func() { _ = p.Put(held) })
+	conn, err = p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if _, err := p.Get(); !errors.Is(err, simpleConnPool.GetConnectionTimeout) {
+		t.Fatalf("Get: got %v, want GetConnectionTimeout", err)
+	}
+	_ = p.Put(conn)
+
+	spans := exporter.GetSpans()
+	want := []string{"created", "idle", "waited", "none"}
+	if len(spans) != len(want) {
+		t.Fatalf("got %d spans, want %d", len(spans), len(want))
+	}
+	for i, span := range spans {
+		attrs := spanAttrs(span)
+		if span.Name != spanName {
+			t.Fatalf("span %d name = %q, want %q", i, span.Name, spanName)
+		}
+		if got := attrs[AttrConnSource].AsString(); got != want[i] {
+			t.Fatalf("span %d source = %q, want %q", i, got, want[i])
+		}
+		if _, ok := attrs[AttrWaitDuration]; !ok {
+			t.Fatalf("span %d has no wait duration", i)
+		}
+	}
+	if got := spanAttrs(spans[2])[AttrWaitDuration].AsFloat64(); got < 5 {
+		t.Fatalf("waited span duration = %vms, want about 10ms", got)
+	}
+	if spans[3].Status.Code != codes.Error || len(spans[3].Events) == 0 {
+		t.Fatalf("timed out span status = %v events = %d, want error with recorded event", spans[3].Status, len(spans[3].Events))
+	}
+}
+
+func TestTracedPoolOtherGets(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(context.Background())
+
+	inner, err := simpleConnPool.NewPool(&simpleConnPool.Config{
+		MaxCap:      2,
+		MaxIdle:     2,
+		Factory:     func() (interface{}, error) { return new(int), nil },
+		Close:       func(interface{}) error { return nil },
+		WaitTimeout: 50 * time.Millisecond,
+		WaitQueue:   1,
+	})
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	p := NewTracedPool(inner, provider.Tracer("test"))
+	defer p.Shutdown()
+
+	ctx := context.Background()
+	conn, err := p.GetWithPriority(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetWithPriority: %v", err)
+	}
+	_ = p.Put(conn)
+	conn, _, err = p.GetWithInfo(ctx)
+	if err != nil {
+		t.Fatalf("GetWithInfo: %v", err)
+	}
+	_ = p.Put(conn)
+	conns, err := p.GetMany(ctx, 2)
+	if err != nil {
+		t.Fatalf("GetMany: %v", err)
+	}
+	if _, err := p.TryGet(); !errors.Is(err, simpleConnPool.ErrPoolExhausted) {
+		t.Fatalf("TryGet: got %v, want ErrPoolExhausted", err)
+	}
+	for _, conn := range conns {
+		_ = p.Put(conn)
+	}
+
+	spans := exporter.GetSpans()
+	want := []string{spanName, spanName, getManySpanName, tryGetSpanName}
+	if len(spans) != len(want) {
+		t.Fatalf("got %d spans, want %d", len(spans), len(want))
+	}
+	for i, span := range spans {
+		if span.Name != want[i] {
+			t.Fatalf("span %d name = %q, want %q", i, span.Name, want[i])
+		}
+	}
+	if got := spanAttrs(spans[0])[AttrConnSource].AsString(); got != "created" {
+		t.Fatalf("GetWithPriority span source = %q, want created", got)
+	}
+	if got := spanAttrs(spans[1])[AttrConnSource].AsString(); got != "idle" {
+		t.Fatalf("GetWithInfo span source = %q, want idle", got)
+	}
+	if got := spanAttrs(spans[2])[AttrGetCount].AsInt64(); got != 2 {
+		t.Fatalf("GetMany span count = %d, want 2", got)
+	}
+	if spans[3].Status.Code != codes.Error {
+		t.Fatalf("TryGet span status = %v, want error", spans[3].Status)
+	}
+}
+
+//spanAttrs 返回 span 的属性
+func spanAttrs(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
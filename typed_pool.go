@@ -0,0 +1,111 @@
+package simpleConnPool
+
+import "context"
+
+/*
+====== 基于泛型的类型安全连接池 =======
+*/
+
+//TypedConfig 类型安全连接池配置
+//Factory FactoryContext 与 Close 为带类型的版本 其余配置项与 Config 相同
+type TypedConfig[T any] struct {
+	Config
+	Factory        func() (T, error)                    //生成连接的方法
+	FactoryContext func(ctx context.Context) (T, error) //生成连接的方法 设置后优先于 Factory 使用
+	Close          func(T) error                        //关闭连接的方法
+}
+
+//TypedPool 类型安全连接池 对 Pool 进行包装 调用方无需再做类型断言
+type TypedPool[T any] struct {
+	pool Pool
+}
+
+//NewTypedPool 构造函数 返回一个类型安全连接池
+func NewTypedPool[T any](cfg *TypedConfig[T]) (*TypedPool[T], error) {
+	poolConfig := cfg.Config
+	poolConfig.Factory = nil
+	poolConfig.FactoryContext = nil
+	poolConfig.Close = nil
+	if cfg.Factory != nil {
+		poolConfig.Factory = func() (interface{}, error) {
+			return cfg.Factory()
+		}
+	}
+	if cfg.FactoryContext != nil {
+		poolConfig.FactoryContext = func(ctx context.Context) (interface{}, error) {
+			return cfg.FactoryContext(ctx)
+		}
+	}
+	if cfg.Close != nil {
+		poolConfig.Close = func(conn interface{}) error {
+			return cfg.Close(conn.(T))
+		}
+	}
+	p, err := NewPool(&poolConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedPool[T]{pool: p}, nil
+}
+
+//Get 向连接池中获取一个连接
+func (p *TypedPool[T]) Get() (T, error) {
+	return p.GetContext(context.Background())
+}
+
+//GetContext 向连接池中获取一个连接 等待过程中 ctx 被取消则返回 ctx.Err()
+func (p *TypedPool[T]) GetContext(ctx context.Context) (T, error) {
+	conn, err := p.pool.GetContext(ctx)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return conn.(T), nil
+}
+
+//Put 向连接池中放入一个连接
+func (p *TypedPool[T]) Put(conn T) error {
+	return p.pool.Put(conn)
+}
+
+//CloseConn 关闭一个借出的连接
+func (p *TypedPool[T]) CloseConn(conn T) error {
+	return p.pool.CloseConn(conn)
+}
+
+//Close 关闭一个借出的连接
+//
+//Deprecated: 请使用 CloseConn 关闭单个连接 使用 Shutdown 关闭连接池
+func (p *TypedPool[T]) Close(conn T) error {
+	return p.CloseConn(conn)
+}
+
+//Invalidate 关闭一个已损坏的借出连接 连接使用中出现 I/O 错误时应调用此方法
+func (p *TypedPool[T]) Invalidate(conn T) error {
+	return p.pool.Invalidate(conn)
+}
+
+//RefreshConn 关闭一个借出的连接 沿用其占用的连接数创建一个新连接并借出
+func (p *TypedPool[T]) RefreshConn(old T) (T, error) {
+	conn, err := p.pool.RefreshConn(old)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return conn.(T), nil
+}
+
+//Shutdown 关闭整个连接池
+func (p *TypedPool[T]) Shutdown() error {
+	return p.pool.Shutdown()
+}
+
+//Ping 借出一个连接检测后归还 连接可用时返回 nil
+func (p *TypedPool[T]) Ping(ctx context.Context) error {
+	return p.pool.Ping(ctx)
+}
+
+//Stats 返回连接池当前的运行状态
+func (p *TypedPool[T]) Stats() Stats {
+	return p.pool.Stats()
+}
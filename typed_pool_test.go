@@ -0,0 +1,64 @@
+package simpleConnPool
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTypedPool(t *testing.T) {
+	var closed []*bytes.Buffer
+	p, err := NewTypedPool(&TypedConfig[*bytes.Buffer]{
+		Config: Config{
+			MaxCap:      2,
+			MaxIdle:     1,
+			WaitTimeout: time.Second,
+			WaitQueue:   1,
+		},
+		Factory: func() (*bytes.Buffer, error) { return new(bytes.Buffer), nil },
+		Close: func(buf *bytes.Buffer) error {
+			closed = append(closed, buf)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewTypedPool: %v", err)
+	}
+
+	buf, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	buf.WriteString("hello")
+	if err := p.Put(buf); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	again, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if again != buf || again.String() != "hello" {
+		t.Fatalf("Get returned %p %q, want the returned buffer", again, again.String())
+	}
+	if err := p.Put(again); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	if err := p.Shutdown(); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if len(closed) != 1 || closed[0] != buf {
+		t.Fatalf("typed Close called with %v, want [%p]", closed, buf)
+	}
+}
+
+func TestTypedPoolInvalidConfig(t *testing.T) {
+	_, err := NewTypedPool(&TypedConfig[*bytes.Buffer]{
+		Config: Config{MaxCap: 1, MaxIdle: 1},
+		Close:  func(*bytes.Buffer) error { return nil },
+	})
+	if !errors.Is(err, InvalidFactorySet) {
+		t.Fatalf("NewTypedPool: got %v, want InvalidFactorySet", err)
+	}
+}